@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"strings"
 
 	"github.com/x-color/monkey/token"
@@ -11,6 +12,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // Position of first charactor of node
+	End() token.Position // Position just after last charactor of node
 }
 
 // Statement is statement's interface
@@ -28,6 +31,7 @@ type Expression interface {
 // Program is root node in AST
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // All comments in source order (if lexer keeps comments)
 }
 
 // TokenLiteral returns current node's token literal
@@ -47,8 +51,25 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// Pos returns position of first statement
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+// End returns end position of last statement
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 // LetStatement is 'let' statement node in AST
 type LetStatement struct {
+	Trivia
 	Token token.Token // 'let' token
 	Name  *Identifier // Variable token
 	Value Expression
@@ -80,8 +101,22 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// Pos returns position of 'let'
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
+
+// End returns end position of assigned value
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Name.End()
+}
+
 // ReturnStatement is 'return' statement node in AST
 type ReturnStatement struct {
+	Trivia
 	Token       token.Token // 'return' token
 	ReturnValue Expression  // Return expression
 }
@@ -111,8 +146,82 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// Pos returns position of 'return'
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
+
+// End returns end position of return expression
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
+
+// BreakStatement is 'break' statement node in AST
+type BreakStatement struct {
+	Trivia
+	Token token.Token // 'break' token
+}
+
+func (bs *BreakStatement) statementNode() {
+
+}
+
+// TokenLiteral returns 'break'
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+// String returns 'break' statement
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
+
+// Pos returns position of 'break'
+func (bs *BreakStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+// End returns end position of 'break'
+func (bs *BreakStatement) End() token.Position {
+	return bs.Token.End
+}
+
+// ContinueStatement is 'continue' statement node in AST
+type ContinueStatement struct {
+	Trivia
+	Token token.Token // 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode() {
+
+}
+
+// TokenLiteral returns 'continue'
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+// String returns 'continue' statement
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
+
+// Pos returns position of 'continue'
+func (cs *ContinueStatement) Pos() token.Position {
+	return cs.Token.Pos
+}
+
+// End returns end position of 'continue'
+func (cs *ContinueStatement) End() token.Position {
+	return cs.Token.End
+}
+
 // ExpressionStatement is expression node in AST
 type ExpressionStatement struct {
+	Trivia
 	Token      token.Token // First token of expression statement
 	Expression Expression  // Expression node
 }
@@ -134,10 +243,27 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
+// Pos returns position of expression
+func (es *ExpressionStatement) Pos() token.Position {
+	if es.Expression != nil {
+		return es.Expression.Pos()
+	}
+	return es.Token.Pos
+}
+
+// End returns end position of expression
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
+
 // BlockStatement is block statement({...}) node in AST
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // '{' token
 	Statements []Statement
+	RBrace     token.Token // '}' token
 }
 
 func (bs *BlockStatement) statementNode() {
@@ -160,6 +286,22 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+// Pos returns position of '{'
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+// End returns end position of '}'
+func (bs *BlockStatement) End() token.Position {
+	if bs.RBrace.End.IsValid() {
+		return bs.RBrace.End
+	}
+	if len(bs.Statements) > 0 {
+		return bs.Statements[len(bs.Statements)-1].End()
+	}
+	return bs.Token.End
+}
+
 // Identifier is variable node in AST
 type Identifier struct {
 	Token token.Token // Variable token
@@ -180,10 +322,51 @@ func (i *Identifier) String() string {
 	return i.Value
 }
 
+// Pos returns position of variable
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+
+// End returns end position of variable
+func (i *Identifier) End() token.Position {
+	return i.Token.End
+}
+
+// FloatLiteral is floating-point number literal node in AST
+type FloatLiteral struct {
+	Token token.Token // Float literal token
+	Value float64     // Float literal value
+}
+
+func (fl *FloatLiteral) expressionNode() {
+
+}
+
+// TokenLiteral returns float literal value
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+// String returns float literal value
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
+// Pos returns position of float literal
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+// End returns end position of float literal
+func (fl *FloatLiteral) End() token.Position {
+	return fl.Token.End
+}
+
 // IntegerLiteral is integer literal node in AST
 type IntegerLiteral struct {
 	Token token.Token // Integer literal token
 	Value int64       // Integer literal value
+	Big   *big.Int    // Integer literal value out of range of int64 (nil otherwise)
 }
 
 func (il *IntegerLiteral) expressionNode() {
@@ -200,6 +383,16 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// Pos returns position of integer literal
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+
+// End returns end position of integer literal
+func (il *IntegerLiteral) End() token.Position {
+	return il.Token.End
+}
+
 // StringLiteral is string literal node in AST
 type StringLiteral struct {
 	Token token.Token
@@ -220,6 +413,16 @@ func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+// Pos returns position of string literal
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
+
+// End returns end position of string literal
+func (sl *StringLiteral) End() token.Position {
+	return sl.Token.End
+}
+
 // PrefixExpression is prefix expression node in AST
 type PrefixExpression struct {
 	Token    token.Token // Prefix operator token
@@ -246,6 +449,19 @@ func (pe *PrefixExpression) String() string {
 	return out.String()
 }
 
+// Pos returns position of prefix operator
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
+
+// End returns end position of expression after prefix operator
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+	return pe.Token.End
+}
+
 // InfixExpression is infix expression node in AST
 type InfixExpression struct {
 	Token    token.Token // Infix operator token
@@ -274,6 +490,63 @@ func (oe *InfixExpression) String() string {
 	return out.String()
 }
 
+// Pos returns position of expression before infix operator
+func (oe *InfixExpression) Pos() token.Position {
+	if oe.Left != nil {
+		return oe.Left.Pos()
+	}
+	return oe.Token.Pos
+}
+
+// End returns end position of expression after infix operator
+func (oe *InfixExpression) End() token.Position {
+	if oe.Right != nil {
+		return oe.Right.End()
+	}
+	return oe.Token.End
+}
+
+// AssignExpression is assignment node in AST (e.g. 'x = 1', 'arr[0] += 2')
+type AssignExpression struct {
+	Token    token.Token // Assignment operator token
+	Target   Expression  // Assigned identifier or index expression
+	Operator string      // '=' or compound assignment operator (e.g. '+=')
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {
+
+}
+
+// TokenLiteral returns assignment operator
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+// String returns assignment expression
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// Pos returns position of assigned expression
+func (ae *AssignExpression) Pos() token.Position {
+	return ae.Target.Pos()
+}
+
+// End returns end position of assigned value
+func (ae *AssignExpression) End() token.Position {
+	if ae.Value != nil {
+		return ae.Value.End()
+	}
+	return ae.Token.End
+}
+
 // Boolean is boolean node in AST
 type Boolean struct {
 	Token token.Token
@@ -294,6 +567,16 @@ func (b *Boolean) String() string {
 	return b.Token.Literal
 }
 
+// Pos returns position of boolean literal
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
+
+// End returns end position of boolean literal
+func (b *Boolean) End() token.Position {
+	return b.Token.End
+}
+
 // IfExpression is 'if-else' expression node in AST
 type IfExpression struct {
 	Token       token.Token
@@ -328,9 +611,26 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// Pos returns position of 'if'
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
+// End returns end position of last block
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	if ie.Consequence != nil {
+		return ie.Consequence.End()
+	}
+	return ie.Token.End
+}
+
 // FunctionLiteral is function node in AST
 type FunctionLiteral struct {
 	Token      token.Token
+	Name       string // Name bound by 'let' statement
 	Parameters []*Identifier
 	Body       *BlockStatement
 }
@@ -362,11 +662,25 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// Pos returns position of 'fn'
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+// End returns end position of function body
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+	return fl.Token.End
+}
+
 // CallExpression is calling function node in AST
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // '(' token
 	Function  Expression
 	Arguments []Expression
+	RParen    token.Token // ')' token
 }
 
 func (ce *CallExpression) expressionNode() {
@@ -395,10 +709,24 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// Pos returns position of called function
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Function.Pos()
+}
+
+// End returns end position of ')'
+func (ce *CallExpression) End() token.Position {
+	if ce.RParen.End.IsValid() {
+		return ce.RParen.End
+	}
+	return ce.Token.End
+}
+
 // ArrayLiteral is array node in AST
 type ArrayLiteral struct {
-	Token    token.Token
+	Token    token.Token // '[' token
 	Elements []Expression
+	RBracket token.Token // ']' token
 }
 
 func (al *ArrayLiteral) expressionNode() {
@@ -426,11 +754,25 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// Pos returns position of '['
+func (al *ArrayLiteral) Pos() token.Position {
+	return al.Token.Pos
+}
+
+// End returns end position of ']'
+func (al *ArrayLiteral) End() token.Position {
+	if al.RBracket.End.IsValid() {
+		return al.RBracket.End
+	}
+	return al.Token.End
+}
+
 // IndexExpression is array node in AST
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token // '[' token
+	Left     Expression
+	Index    Expression
+	RBracket token.Token // ']' token
 }
 
 func (ie *IndexExpression) expressionNode() {
@@ -455,10 +797,31 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
-// HashLiteral is associative array node in AST
+// Pos returns position of indexed expression
+func (ie *IndexExpression) Pos() token.Position {
+	return ie.Left.Pos()
+}
+
+// End returns end position of ']'
+func (ie *IndexExpression) End() token.Position {
+	if ie.RBracket.End.IsValid() {
+		return ie.RBracket.End
+	}
+	return ie.Token.End
+}
+
+// HashPair is pair of key and value in hash literal
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashLiteral is associative array node in AST.
+// Pairs are in source order.
 type HashLiteral struct {
-	Token token.Token
-	Pairs map[Expression]Expression
+	Token  token.Token // '{' token
+	Pairs  []HashPair
+	RBrace token.Token // '}' token
 }
 
 func (hl *HashLiteral) expressionNode() {
@@ -475,8 +838,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -486,6 +849,19 @@ func (hl *HashLiteral) String() string {
 	return out.String()
 }
 
+// Pos returns position of '{'
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
+
+// End returns end position of '}'
+func (hl *HashLiteral) End() token.Position {
+	if hl.RBrace.End.IsValid() {
+		return hl.RBrace.End
+	}
+	return hl.Token.End
+}
+
 // WhileExpression is 'while' expression node in AST
 type WhileExpression struct {
 	Token       token.Token
@@ -513,3 +889,118 @@ func (we *WhileExpression) String() string {
 
 	return out.String()
 }
+
+// Pos returns position of 'while'
+func (we *WhileExpression) Pos() token.Position {
+	return we.Token.Pos
+}
+
+// End returns end position of loop body
+func (we *WhileExpression) End() token.Position {
+	if we.Consequence != nil {
+		return we.Consequence.End()
+	}
+	return we.Token.End
+}
+
+// ForExpression is C-style 'for' loop node in AST (e.g. 'for (let i = 0; i < n; i += 1) { ... }')
+type ForExpression struct {
+	Token     token.Token // 'for' token
+	Init      Statement   // Statement executed before loop (nil if omitted)
+	Condition Expression  // Loop continues while condition is truthy (nil if omitted)
+	Post      Expression  // Expression evaluated after each iteration (nil if omitted)
+	Body      *BlockStatement
+}
+
+func (fe *ForExpression) expressionNode() {
+
+}
+
+// TokenLiteral returns 'for'
+func (fe *ForExpression) TokenLiteral() string {
+	return fe.Token.Literal
+}
+
+// String returns 'for' expression
+func (fe *ForExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fe.Init != nil {
+		out.WriteString(strings.TrimSuffix(fe.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fe.Condition != nil {
+		out.WriteString(fe.Condition.String())
+	}
+	out.WriteString("; ")
+	if fe.Post != nil {
+		out.WriteString(fe.Post.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(fe.Body.String())
+
+	return out.String()
+}
+
+// Pos returns position of 'for'
+func (fe *ForExpression) Pos() token.Position {
+	return fe.Token.Pos
+}
+
+// End returns end position of loop body
+func (fe *ForExpression) End() token.Position {
+	if fe.Body != nil {
+		return fe.Body.End()
+	}
+	return fe.Token.End
+}
+
+// ForInExpression is 'for ... in' loop node in AST (e.g. 'for (i, x in arr) { ... }')
+type ForInExpression struct {
+	Token     token.Token   // 'for' token
+	Variables []*Identifier // One or two loop variables
+	Iterable  Expression
+	Body      *BlockStatement
+}
+
+func (fe *ForInExpression) expressionNode() {
+
+}
+
+// TokenLiteral returns 'for'
+func (fe *ForInExpression) TokenLiteral() string {
+	return fe.Token.Literal
+}
+
+// String returns 'for ... in' expression
+func (fe *ForInExpression) String() string {
+	var out bytes.Buffer
+
+	vars := []string{}
+	for _, v := range fe.Variables {
+		vars = append(vars, v.String())
+	}
+
+	out.WriteString("for (")
+	out.WriteString(strings.Join(vars, ", "))
+	out.WriteString(" in ")
+	out.WriteString(fe.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fe.Body.String())
+
+	return out.String()
+}
+
+// Pos returns position of 'for'
+func (fe *ForInExpression) Pos() token.Position {
+	return fe.Token.Pos
+}
+
+// End returns end position of loop body
+func (fe *ForInExpression) End() token.Position {
+	if fe.Body != nil {
+		return fe.Body.End()
+	}
+	return fe.Token.End
+}
@@ -0,0 +1,49 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/x-color/monkey/token"
+)
+
+// Comment is comment in source code ('// ...' or '/* ... */')
+type Comment struct {
+	Token token.Token // Comment token
+}
+
+// Text returns comment text without comment markers
+func (c *Comment) Text() string {
+	text := c.Token.Literal
+	if strings.HasPrefix(text, "//") {
+		return strings.TrimPrefix(text, "//")
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+}
+
+// Pos returns position of comment
+func (c *Comment) Pos() token.Position {
+	return c.Token.Pos
+}
+
+// End returns end position of comment
+func (c *Comment) End() token.Position {
+	return c.Token.End
+}
+
+// Trivia is comments attached to statement by parser.
+// Comments are kept only if lexer keeps them (see lexer.Lexer.KeepComments).
+type Trivia struct {
+	Leading  []*Comment // Comments just before statement
+	Trailing []*Comment // Comments after statement on the same line
+}
+
+// Comments returns comments attached to statement
+func (t *Trivia) Comments() *Trivia {
+	return t
+}
+
+// Commented is node which comments can be attached to
+type Commented interface {
+	Node
+	Comments() *Trivia
+}
@@ -0,0 +1,903 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/x-color/monkey/ast"
+	"github.com/x-color/monkey/code"
+	"github.com/x-color/monkey/object"
+)
+
+// Compiler compiles AST to bytecode
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	scopeIndex  int
+	node        ast.Node // Node which emitted instructions are compiled from
+}
+
+// EmittedInstruction is opcode and position of emitted instruction
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope has instructions of function being compiled
+type CompilationScope struct {
+	instructions        code.Instructions
+	sourceMap           code.SourceMap
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	stackDepth          int          // Number of values pushed on stack at end of instructions
+	loops               []*loopScope // Loops enclosing instructions being compiled
+}
+
+// loopScope has info of loop to compile 'break' and 'continue'
+type loopScope struct {
+	bodyDepth     int   // Stack depth at start of loop body
+	breakJumps    []int // Positions of jumps by 'break' to be changed to end of loop
+	continueJumps []int // Positions of jumps by 'continue' to be changed to next iteration
+}
+
+// Bytecode is compiled program
+type Bytecode struct {
+	Instructions code.Instructions
+	SourceMap    code.SourceMap
+	Constants    []object.Object
+	GlobalNames  []string // Names of global variables (index is global index)
+}
+
+// New makes new compiler
+func New() *Compiler {
+	symbolTable := NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	return NewWithState(symbolTable, []object.Object{})
+}
+
+// NewWithState makes new compiler keeping symbols and constants of previous compilation
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	mainScope := CompilationScope{
+		instructions: code.Instructions{},
+		sourceMap:    code.SourceMap{},
+	}
+
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []CompilationScope{mainScope},
+	}
+}
+
+// Compile compiles node of AST
+func (c *Compiler) Compile(node ast.Node) error {
+	outer := c.node
+	c.node = node
+	defer func() { c.node = outer }()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+
+	case *ast.BreakStatement:
+		return c.compileLoopControl(true)
+
+	case *ast.ContinueStatement:
+		return c.compileLoopControl(false)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		case "~":
+			c.emit(code.OpBitNot)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		return c.emitInfixOperator(node.Operator)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.WhileExpression:
+		return c.compileWhileExpression(node)
+
+	case *ast.ForExpression:
+		return c.compileForExpression(node)
+
+	case *ast.ForInExpression:
+		return c.compileForInExpression(node)
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			symbol = c.symbolTable.DefineUnresolved(node.Value)
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.IntegerLiteral:
+		var integer object.Object = &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			integer = &object.BigInt{Value: node.Big}
+		}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	default:
+		return fmt.Errorf("unsupported node %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	var symbol Symbol
+	if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+		// Define name before compiling function so that function can call itself
+		symbol = c.symbolTable.Define(node.Name.Value)
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+	} else {
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol = c.symbolTable.Define(node.Name.Value)
+	}
+
+	c.storeSymbol(symbol)
+	return nil
+}
+
+// emitInfixOperator emits instruction of infix operator applied to two values on stack
+func (c *Compiler) emitInfixOperator(operator string) error {
+	switch operator {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case "%":
+		c.emit(code.OpMod)
+	case "**":
+		c.emit(code.OpPow)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case "<":
+		c.emit(code.OpLessThan)
+	case ">=":
+		c.emit(code.OpGreaterEqual)
+	case "<=":
+		c.emit(code.OpLessEqual)
+	case "&":
+		c.emit(code.OpBitAnd)
+	case "|":
+		c.emit(code.OpBitOr)
+	case "^":
+		c.emit(code.OpBitXor)
+	case "<<":
+		c.emit(code.OpShiftLeft)
+	case ">>":
+		c.emit(code.OpShiftRight)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	default:
+		return fmt.Errorf("unknown operator %s", operator)
+	}
+	return nil
+}
+
+// compileAssignExpression compiles assignment leaving assigned value on stack
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	compound := node.Operator != "="
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			symbol = c.symbolTable.DefineUnresolved(target.Value)
+		}
+		if symbol.Scope == BuiltinScope {
+			// Error is raised at runtime like evaluator
+			c.emit(code.OpAssignBuiltin, symbol.Index)
+		}
+
+		if compound {
+			c.loadSymbol(symbol)
+		}
+		if err := c.compileAssignedValue(node); err != nil {
+			return err
+		}
+
+		switch symbol.Scope {
+		case GlobalScope:
+			c.emit(code.OpAssignGlobal, symbol.Index)
+		case LocalScope:
+			c.emit(code.OpAssignLocal, symbol.Index)
+		case FreeScope:
+			c.emit(code.OpAssignFree, symbol.Index)
+		}
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if compound {
+			// Keep indexed object and index for 'OpSetIndex'
+			c.emit(code.OpDupPair)
+			c.emit(code.OpIndex)
+		}
+		if err := c.compileAssignedValue(node); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex)
+
+	default:
+		return fmt.Errorf("cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+// compileAssignedValue compiles value of assignment.
+// Operator of compound assignment is applied to current value pushed before the value.
+func (c *Compiler) compileAssignedValue(node *ast.AssignExpression) error {
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	if node.Operator == "=" {
+		return nil
+	}
+	return c.emitInfixOperator(strings.TrimSuffix(node.Operator, "="))
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Emit 'OpJumpNotTruthy' with bogus value
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	// Emit 'OpJump' with bogus value
+	jumpPos := c.emit(code.OpJump, 9999)
+	// Value of consequence is not pushed when alternative is executed
+	c.scopes[c.scopeIndex].stackDepth--
+
+	afterConsequencePos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.compileBlockValue(node.Alternative); err != nil {
+			return err
+		}
+	}
+
+	afterAlternativePos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterAlternativePos)
+
+	return nil
+}
+
+// compileLogicalExpression compiles '&&' or '||' skipping right operand if left operand determines result
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	// Emit 'OpJumpNotTruthy' with bogus value
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	var jumpPos int
+	if node.Operator == "&&" {
+		if err := c.compileTruthiness(node.Right); err != nil {
+			return err
+		}
+		jumpPos = c.emit(code.OpJump, 9999)
+		c.scopes[c.scopeIndex].stackDepth--
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+	} else {
+		c.emit(code.OpTrue)
+		jumpPos = c.emit(code.OpJump, 9999)
+		c.scopes[c.scopeIndex].stackDepth--
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		if err := c.compileTruthiness(node.Right); err != nil {
+			return err
+		}
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileTruthiness compiles expression converting its value to boolean
+func (c *Compiler) compileTruthiness(node ast.Expression) error {
+	if err := c.Compile(node); err != nil {
+		return err
+	}
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+	return nil
+}
+
+// compileWhileExpression compiles loop leaving value of last evaluated body (or null) on stack
+func (c *Compiler) compileWhileExpression(node *ast.WhileExpression) error {
+	c.emit(code.OpNull)
+
+	conditionPos := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Emit 'OpJumpNotTruthy' with bogus value
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	// Discard value of previous iteration
+	c.emit(code.OpPop)
+	loop, err := c.compileLoopBody(node.Consequence)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, conditionPos)
+
+	afterBodyPos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterBodyPos)
+	loop.patchJumps(c, conditionPos, afterBodyPos)
+
+	return nil
+}
+
+// compileForExpression compiles C-style 'for' loop like 'while' loop.
+// 'continue' jumps to post expression.
+func (c *Compiler) compileForExpression(node *ast.ForExpression) error {
+	if node.Init != nil {
+		if err := c.Compile(node.Init); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpNull)
+
+	conditionPos := len(c.currentInstructions())
+	jumpNotTruthyPos := -1
+	if node.Condition != nil {
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		// Emit 'OpJumpNotTruthy' with bogus value
+		jumpNotTruthyPos = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+
+	// Discard value of previous iteration
+	c.emit(code.OpPop)
+	loop, err := c.compileLoopBody(node.Body)
+	if err != nil {
+		return err
+	}
+
+	postPos := len(c.currentInstructions())
+	if node.Post != nil {
+		if err := c.Compile(node.Post); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpJump, conditionPos)
+
+	afterBodyPos := len(c.currentInstructions())
+	if jumpNotTruthyPos >= 0 {
+		c.changeOperand(jumpNotTruthyPos, afterBodyPos)
+	}
+	loop.patchJumps(c, postPos, afterBodyPos)
+
+	return nil
+}
+
+// compileForInExpression compiles 'for ... in' loop.
+// Iterator is stored in hidden variable which can not be referred by programs.
+func (c *Compiler) compileForInExpression(node *ast.ForInExpression) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	// Nested loops use different variables
+	name := fmt.Sprintf("(iterator %d)", len(c.scopes[c.scopeIndex].loops))
+	iterator := c.symbolTable.Define(name)
+	c.storeSymbol(iterator)
+
+	c.emit(code.OpNull)
+
+	nextPos := len(c.currentInstructions())
+	c.loadSymbol(iterator)
+	// Emit 'OpIterNext' with bogus jump position
+	iterNextPos := c.emit(code.OpIterNext, len(node.Variables), 9999)
+
+	// Values are pushed in order of variables
+	for i := len(node.Variables) - 1; i >= 0; i-- {
+		c.storeSymbol(c.symbolTable.Define(node.Variables[i].Value))
+	}
+
+	// Discard value of previous iteration
+	c.emit(code.OpPop)
+	loop, err := c.compileLoopBody(node.Body)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, nextPos)
+
+	afterBodyPos := len(c.currentInstructions())
+	c.replaceInstruction(iterNextPos, code.Make(code.OpIterNext, len(node.Variables), afterBodyPos))
+	loop.patchJumps(c, nextPos, afterBodyPos)
+
+	return nil
+}
+
+// compileLoopBody compiles body of loop leaving its value on stack.
+// Returned loop has positions of jumps by 'break' and 'continue' in the body.
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement) (*loopScope, error) {
+	scope := &c.scopes[c.scopeIndex]
+	loop := &loopScope{bodyDepth: scope.stackDepth}
+	scope.loops = append(scope.loops, loop)
+
+	if err := c.compileBlockValue(body); err != nil {
+		return nil, err
+	}
+
+	// Scopes may be reallocated while compiling body
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+	return loop, nil
+}
+
+// patchJumps changes jumps by 'continue' to continuePos and ones by 'break' to breakPos
+func (l *loopScope) patchJumps(c *Compiler, continuePos, breakPos int) {
+	for _, pos := range l.continueJumps {
+		c.changeOperand(pos, continuePos)
+	}
+	for _, pos := range l.breakJumps {
+		c.changeOperand(pos, breakPos)
+	}
+}
+
+// compileLoopControl compiles 'break' (isBreak is true) or 'continue'.
+// Values pushed in loop body are popped and null is pushed as value of loop or iteration.
+func (c *Compiler) compileLoopControl(isBreak bool) error {
+	scope := &c.scopes[c.scopeIndex]
+	if len(scope.loops) == 0 {
+		return fmt.Errorf("%s is not in loop", c.node.TokenLiteral())
+	}
+	loop := scope.loops[len(scope.loops)-1]
+
+	depth := scope.stackDepth
+	for i := depth; i > loop.bodyDepth; i-- {
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpNull)
+
+	// Emit 'OpJump' with bogus value
+	pos := c.emit(code.OpJump, 9999)
+	if isBreak {
+		loop.breakJumps = append(loop.breakJumps, pos)
+	} else {
+		loop.continueJumps = append(loop.continueJumps, pos)
+	}
+
+	// Following instructions are not executed, so stack depth is kept as statement
+	c.scopes[c.scopeIndex].stackDepth = depth
+	return nil
+}
+
+// compileBlockValue compiles block leaving value of its last statement (or null) on stack
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+	c.symbolTable.lets = make(map[string]bool)
+	collectLetNames(node.Body, c.symbolTable.lets)
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	localNames := c.symbolTable.Names()
+	scope := c.scopes[c.scopeIndex]
+	c.leaveScope()
+
+	captures := make([]object.Capture, len(freeSymbols))
+	freeNames := make([]string, len(freeSymbols))
+	for i, s := range freeSymbols {
+		captures[i] = object.Capture{Local: s.Scope == LocalScope, Index: s.Index}
+		freeNames[i] = s.Name
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Name:          node.Name,
+		Instructions:  scope.instructions,
+		SourceMap:     scope.sourceMap,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Captures:      captures,
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+		Definition:    object.InspectFunction(node.Parameters, node.Body),
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFn))
+
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+// storeSymbol emits instruction storing popped value to variable defined by 'let'
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+	c.scopes[c.scopeIndex].stackDepth += stackEffect(op, operands)
+	if c.node != nil {
+		c.scopes[c.scopeIndex].sourceMap[pos] = code.Span{Pos: c.node.Pos(), End: c.node.End()}
+	}
+
+	return pos
+}
+
+// stackEffect returns change of number of values on stack by instruction
+func stackEffect(op code.Opcode, operands []int) int {
+	switch op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull, code.OpGetGlobal,
+		code.OpGetLocal, code.OpGetBuiltin, code.OpGetFree, code.OpClosure:
+		return 1
+	case code.OpDupPair:
+		return 2
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+		code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+		code.OpGreaterEqual, code.OpLessEqual,
+		code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight,
+		code.OpPop, code.OpJumpNotTruthy, code.OpSetGlobal, code.OpSetLocal,
+		code.OpIndex, code.OpReturnValue:
+		return -1
+	case code.OpSetIndex:
+		return -2
+	case code.OpArray, code.OpHash:
+		return 1 - operands[0]
+	case code.OpCall:
+		return -operands[0]
+	case code.OpIterNext:
+		return operands[0] - 1
+	default:
+		return 0
+	}
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+	c.scopes[c.scopeIndex].stackDepth++
+	delete(c.scopes[c.scopeIndex].sourceMap, last.Position)
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions: code.Instructions{},
+		sourceMap:    code.SourceMap{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+// Bytecode returns compiled program
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.Names(),
+	}
+}
+
+// collectLetNames collects names bound by 'let' in node into names.
+// Nested function literals are not walked because they have their own scopes.
+func collectLetNames(node ast.Node, names map[string]bool) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			collectLetNames(s, names)
+		}
+	case *ast.LetStatement:
+		names[node.Name.Value] = true
+		collectLetNames(node.Value, names)
+	case *ast.ExpressionStatement:
+		collectLetNames(node.Expression, names)
+	case *ast.ReturnStatement:
+		collectLetNames(node.ReturnValue, names)
+	case *ast.PrefixExpression:
+		collectLetNames(node.Right, names)
+	case *ast.InfixExpression:
+		collectLetNames(node.Left, names)
+		collectLetNames(node.Right, names)
+	case *ast.AssignExpression:
+		collectLetNames(node.Target, names)
+		collectLetNames(node.Value, names)
+	case *ast.IfExpression:
+		collectLetNames(node.Condition, names)
+		collectLetNames(node.Consequence, names)
+		if node.Alternative != nil {
+			collectLetNames(node.Alternative, names)
+		}
+	case *ast.WhileExpression:
+		collectLetNames(node.Condition, names)
+		collectLetNames(node.Consequence, names)
+	case *ast.ForExpression:
+		if node.Init != nil {
+			collectLetNames(node.Init, names)
+		}
+		if node.Condition != nil {
+			collectLetNames(node.Condition, names)
+		}
+		if node.Post != nil {
+			collectLetNames(node.Post, names)
+		}
+		collectLetNames(node.Body, names)
+	case *ast.ForInExpression:
+		collectLetNames(node.Iterable, names)
+		collectLetNames(node.Body, names)
+	case *ast.CallExpression:
+		collectLetNames(node.Function, names)
+		for _, a := range node.Arguments {
+			collectLetNames(a, names)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			collectLetNames(el, names)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			collectLetNames(pair.Key, names)
+			collectLetNames(pair.Value, names)
+		}
+	case *ast.IndexExpression:
+		collectLetNames(node.Left, names)
+		collectLetNames(node.Index, names)
+	}
+}
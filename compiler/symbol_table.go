@@ -0,0 +1,125 @@
+package compiler
+
+// SymbolScope is scope where symbol is defined
+type SymbolScope string
+
+// Symbol scopes
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope    SymbolScope = "FREE"
+)
+
+// Symbol is variable info resolved at compile time
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable is store of symbols defined in scope
+type SymbolTable struct {
+	Outer       *SymbolTable
+	FreeSymbols []Symbol // Symbols of enclosing scope captured by this scope
+
+	store          map[string]Symbol
+	names          []string        // Names of defined symbols (index is symbol index)
+	lets           map[string]bool // Names bound by 'let' in function body of this scope
+	numDefinitions int
+}
+
+// NewSymbolTable returns new global symbol table
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s}
+}
+
+// NewEnclosedSymbolTable returns new local symbol table enclosed by outer
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define defines symbol in table.
+// Symbol already defined in same scope is reused like 'let' rebinding variable in environment.
+func (s *SymbolTable) Define(name string) Symbol {
+	scope := GlobalScope
+	if s.Outer != nil {
+		scope = LocalScope
+	}
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == scope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Scope: scope, Index: s.numDefinitions}
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	s.numDefinitions++
+	return symbol
+}
+
+// DefineBuiltin defines builtin function symbol
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Scope: BuiltinScope, Index: index}
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineGlobal defines symbol in outermost symbol table
+func (s *SymbolTable) DefineGlobal(name string) Symbol {
+	if s.Outer != nil {
+		return s.Outer.DefineGlobal(name)
+	}
+	return s.Define(name)
+}
+
+// DefineUnresolved defines symbol named name which is not resolved.
+// Name bound by 'let' later in enclosing function is defined as local of the function
+// because environment of the function has it when reference is evaluated (e.g. mutual recursion).
+// Otherwise, it is defined as global variable which may be defined later (or never) at runtime.
+func (s *SymbolTable) DefineUnresolved(name string) Symbol {
+	for t := s; t.Outer != nil; t = t.Outer {
+		if t.lets[name] {
+			t.Define(name)
+			symbol, _ := s.Resolve(name)
+			return symbol
+		}
+	}
+	return s.DefineGlobal(name)
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// Resolve returns symbol named name.
+// Local or free symbol of enclosing scope is captured as free symbol.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		symbol, ok = s.Outer.Resolve(name)
+		if !ok {
+			return symbol, ok
+		}
+
+		if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+			return symbol, ok
+		}
+
+		free := s.defineFree(symbol)
+		return free, true
+	}
+	return symbol, ok
+}
+
+// Names returns names of defined symbols ordered by index
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
@@ -0,0 +1,117 @@
+package diagnostic
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/x-color/monkey/token"
+)
+
+// Severity is importance of diagnostic
+type Severity int
+
+// Severities of diagnostic
+const (
+	Error Severity = iota
+	Warning
+)
+
+// String returns severity name ('error' or 'warning')
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Code identifies kind of diagnostic
+type Code string
+
+// Diagnostic codes
+const (
+	UnexpectedToken Code = "P001" // Next token is not expected token
+	NoPrefixParseFn Code = "P002" // Token can not start expression
+	InvalidInteger  Code = "P003" // Integer literal can not be parsed
+	InvalidFloat    Code = "P004" // Float literal can not be parsed
+	InvalidAssign   Code = "P005" // Left side of assignment is not variable or index expression
+	OutsideLoop     Code = "P006" // 'break' or 'continue' is not in loop
+
+	UnterminatedString  Code = "L001" // String literal is not closed
+	InvalidEscape       Code = "L002" // Escape sequence in string literal is invalid
+	UnterminatedComment Code = "L003" // Block comment is not closed
+)
+
+// Diagnostic is problem found in source code
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Message  string
+	Pos      token.Position  // Start of problematic span
+	End      token.Position  // End of problematic span
+	Expected token.TokenType // Expected token type (empty if not relevant)
+	Actual   token.Token     // Token actually found
+}
+
+// Error returns position and message (e.g. 'file:1:5: <Message>')
+func (d *Diagnostic) Error() string {
+	return d.Pos.String() + ": " + d.Message
+}
+
+// String returns position, severity, code and message
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Pos, d.Severity, d.Code, d.Message)
+}
+
+// Render returns diagnostic with source snippet marking its span with carets
+func (d *Diagnostic) Render(src string) string {
+	var out bytes.Buffer
+
+	out.WriteString(d.String())
+	out.WriteString("\n")
+	out.WriteString(Snippet(src, d.Pos, d.End))
+
+	return out.String()
+}
+
+// Snippet returns source line of pos with carets under span from pos to end
+func Snippet(src string, pos, end token.Position) string {
+	if !pos.IsValid() {
+		return ""
+	}
+	lines := strings.Split(src, "\n")
+	if pos.Line > len(lines) {
+		return ""
+	}
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+
+	width := 1
+	if end.Line == pos.Line && end.Column > pos.Column {
+		width = end.Column - pos.Column
+	}
+
+	var out bytes.Buffer
+	lineNo := fmt.Sprintf("%d", pos.Line)
+	gutter := strings.Repeat(" ", len(lineNo))
+
+	out.WriteString(fmt.Sprintf(" %s | %s\n", lineNo, line))
+	out.WriteString(fmt.Sprintf(" %s | ", gutter))
+	for i, ch := range []rune(line) {
+		if i >= pos.Column-1 {
+			break
+		}
+		if ch == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+	out.WriteString(strings.Repeat("^", width))
+	out.WriteString("\n")
+
+	return out.String()
+}
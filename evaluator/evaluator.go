@@ -1,62 +1,179 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/x-color/monkey/ast"
 	"github.com/x-color/monkey/object"
+	"github.com/x-color/monkey/token"
 )
 
 // Constant boolean and null objects
 var (
-	Null  = &object.Null{}
-	True  = &object.Boolean{Value: true}
-	False = &object.Boolean{Value: false}
+	Null  = object.NullValue
+	True  = object.TrueValue
+	False = object.FalseValue
 )
 
+// Signals of 'break' and 'continue' propagated to enclosing loop
+var (
+	breakSignal    = &object.Break{}
+	continueSignal = &object.Continue{}
+)
+
+// Evaluator evaluates AST under context and limits
+type Evaluator struct {
+	ctx    context.Context
+	limits Limits
+
+	steps     int64 // Number of evaluated nodes
+	depth     int   // Depth of nested function calls
+	allocated int64 // Approximate bytes allocated
+	stopped   error // Cause of stopping evaluation (nil if running)
+	running   bool  // Whether Eval or Apply called from outside is running
+
+	current ast.Node // Innermost node being evaluated (used to locate Go panic)
+
+	io       *object.IO                 // Standard input and outputs used by builtin functions
+	builtins map[string]*object.Builtin // Builtin functions added to default ones (e.g. by host program)
+}
+
+// New returns new evaluator.
+// Evaluation stops with error when ctx is done or limits are exceeded.
+func New(ctx context.Context, limits Limits) *Evaluator {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if limits.MaxCallDepth <= 0 {
+		limits.MaxCallDepth = DefaultMaxCallDepth
+	}
+	return &Evaluator{ctx: ctx, limits: limits, io: object.DefaultIO()}
+}
+
+// SetIO sets standard input and outputs used by builtin functions (e.g. 'puts').
+// Process IO is used by default.
+func (e *Evaluator) SetIO(io *object.IO) {
+	e.io = io
+}
+
+// SetBuiltins sets builtin functions added to default builtin functions.
+// They override default ones with the same name and cannot be assigned like default ones.
+func (e *Evaluator) SetBuiltins(builtins map[string]*object.Builtin) {
+	e.builtins = builtins
+}
+
 // Eval evaluates node of AST and retruns evaluated node
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(context.Background(), Limits{}).Eval(node, env)
+}
+
+// Apply calls function object fn with args and returns its result
+func Apply(fn object.Object, args ...object.Object) object.Object {
+	return New(context.Background(), Limits{}).Apply(fn, args...)
+}
+
+// Eval evaluates node of AST and retruns evaluated node.
+// Go panic in evaluation is returned as internal error.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) (result object.Object) {
+	if !e.running {
+		e.running = true
+		defer e.recoverPanic(&result)
+	}
+
+	if err := e.step(); err != nil {
+		result = err
+	} else {
+		parent := e.current
+		e.current = node
+		result = e.eval(node, env)
+		e.current = parent
+	}
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+		err.End = node.End()
+	}
+	return result
+}
+
+// Apply calls function object fn with args and returns its result
+func (e *Evaluator) Apply(fn object.Object, args ...object.Object) (result object.Object) {
+	if !e.running {
+		e.running = true
+		defer e.recoverPanic(&result)
+	}
+	return e.applyFunction(fn, args, token.Position{})
+}
+
+// recoverPanic converts Go panic in evaluation started from outside to error object
+// located at node being evaluated. It must be deferred by entry point of evaluation.
+func (e *Evaluator) recoverPanic(result *object.Object) {
+	e.running = false
+	current := e.current
+	e.current = nil
+	if r := recover(); r != nil {
+		err := newError("internal error: %v", r)
+		if current != nil {
+			err.Pos = current.Pos()
+			err.End = current.End()
+		}
+		*result = err
+	}
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.Eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.alloc(evalPrefixExpression(node.Operator, right))
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, env)
+		}
+		left := e.Eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.Eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return e.alloc(e.evalInfixExpression(node.Operator, left, right))
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		val := e.Eval(node.ReturnValue, env)
+		if isAbrupt(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.BreakStatement:
+		return breakSignal
+
+	case *ast.ContinueStatement:
+		return continueSignal
+
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		val := e.Eval(node.Value, env)
+		if isAbrupt(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
@@ -64,51 +181,66 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return e.alloc(&object.Function{Name: node.Name, Parameters: params, Env: env, Body: body})
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
+		function := e.Eval(node.Function, env)
+		if isAbrupt(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isAbrupt(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return e.applyFunction(function, args, node.Pos())
 
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return e.alloc(&object.Array{Elements: elements})
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := e.Eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
-		if isError(index) {
+		index := e.Eval(node.Index, env)
+		if isAbrupt(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
 
 	case *ast.WhileExpression:
-		return evalWhileExpression(node, env)
+		return e.evalWhileExpression(node, env)
+
+	case *ast.ForExpression:
+		return e.evalForExpression(node, env)
+
+	case *ast.ForInExpression:
+		return e.evalForInExpression(node, env)
+
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			return e.alloc(&object.BigInt{Value: node.Big})
+		}
+		return e.alloc(&object.Integer{Value: node.Value})
+
+	case *ast.FloatLiteral:
+		return e.alloc(&object.Float{Value: node.Value})
 
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return e.alloc(&object.String{Value: node.Value})
 
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
@@ -116,17 +248,20 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	return nil
 }
 
-func isError(obj object.Object) bool {
-	if obj != nil {
-		return obj.Type() == object.ErrorObj
+// isAbrupt reports whether obj is error, return value, 'break' or 'continue'.
+// It stops evaluation of enclosing expressions and is propagated as it is.
+func isAbrupt(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+		return true
 	}
 	return false
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -151,6 +286,8 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalBitNotPrefixOperatorExpression(right)
 	default:
 		return newError("unknown oprator: %s%s", operator, right.Type())
 	}
@@ -170,20 +307,39 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.IntegerObj {
+	switch right := right.(type) {
+	case *object.Integer, *object.BigInt:
+		return object.NegateInteger(right)
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
+}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+func evalBitNotPrefixOperatorExpression(right object.Object) object.Object {
+	if right.Type() != object.IntegerObj {
+		return newError("unknown operator: ~%s", right.Type())
+	}
+	return object.BitNotInteger(right)
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.IntegerObj && right.Type() == object.IntegerObj:
-		return evalIntegerInfixExpression(operator, left, right)
+		// Result overflowing int64 is promoted to big integer
+		return object.IntegerInfix(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// Integer is converted to float if either operand is float
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.StringObj && right.Type() == object.StringObj:
 		return evalStringInfixExpression(operator, left, right)
+	case operator == "*" && left.Type() == object.StringObj && right.Type() == object.IntegerObj:
+		count, _ := object.ClampInt(right)
+		return object.RepeatString(&builtinRuntime{e: e}, left.(*object.String).Value, count)
+	case operator == "*" && left.Type() == object.IntegerObj && right.Type() == object.StringObj:
+		count, _ := object.ClampInt(left)
+		return object.RepeatString(&builtinRuntime{e: e}, right.(*object.String).Value, count)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -197,23 +353,31 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return &object.Float{Value: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -224,62 +388,224 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.IntegerObj || obj.Type() == object.FloatObj
+}
+
+// toFloat converts integer or float object to float64
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		return obj.Float()
+	default:
+		return obj.(*object.Float).Value
 	}
+}
 
+// evalStringInfixExpression concatenates or compares strings.
+// Strings are compared by value in byte-wise lexical order.
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+// evalLogicalExpression evaluates '&&' or '||' to boolean.
+// Right operand is not evaluated if left operand determines result.
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+	if node.Operator == "&&" && !isTruthry(left) {
+		return False
+	}
+	if node.Operator == "||" && isTruthry(left) {
+		return True
+	}
+
+	right := e.Eval(node.Right, env)
+	if isAbrupt(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthry(right))
+}
+
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.Eval(ie.Condition, env)
+	if isAbrupt(condition) {
 		return condition
 	}
 
 	if isTruthry(condition) {
-		return Eval(ie.Consequence, env)
+		return e.Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.Eval(ie.Alternative, env)
 	} else {
 		return Null
 	}
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if result != nil {
-			rt := result.Type()
-			if rt == object.ReturnValueObj || rt == object.ErrorObj {
+			switch result.Type() {
+			case object.ReturnValueObj, object.ErrorObj, object.BreakObj, object.ContinueObj:
 				return result
 			}
 		}
 	}
+	if result == nil {
+		// Empty block and block ending with 'let' have no value
+		return Null
+	}
 	return result
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin := e.builtin(node.Value); builtin != nil {
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+// builtin returns builtin function named name or nil
+func (e *Evaluator) builtin(name string) *object.Builtin {
+	if builtin, ok := e.builtins[name]; ok {
 		return builtin
 	}
-	return newError("identifier not found: " + node.Value)
+	return object.GetBuiltinByName(name)
+}
+
+// evalAssignExpression updates variable or element and returns assigned value
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		name := target.Value
+		if _, ok := env.Get(name); !ok && e.builtin(name) != nil {
+			return newError("cannot assign to builtin function: %s", name)
+		}
+
+		var current object.Object
+		if node.Operator != "=" {
+			current = e.evalIdentifier(target, env)
+			if isAbrupt(current) {
+				return current
+			}
+		}
+		val := e.evalAssignedValue(node, current, env)
+		if isAbrupt(val) {
+			return val
+		}
+
+		if !env.Assign(name, val) {
+			return newError("identifier not found: %s", name)
+		}
+		return val
+
+	case *ast.IndexExpression:
+		left := e.Eval(target.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := e.Eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIndexExpression(left, index)
+			if isAbrupt(current) {
+				return current
+			}
+		}
+		val := e.evalAssignedValue(node, current, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return e.evalIndexAssignment(left, index, val)
+
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalAssignedValue evaluates value of assignment.
+// Compound assignment (e.g. '+=') applies its operator to current value and the value.
+func (e *Evaluator) evalAssignedValue(node *ast.AssignExpression, current object.Object, env *object.Environment) object.Object {
+	val := e.Eval(node.Value, env)
+	if isAbrupt(val) || node.Operator == "=" {
+		return val
+	}
+	operator := strings.TrimSuffix(node.Operator, "=")
+	return e.alloc(e.evalInfixExpression(operator, current, val))
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+// evalIndexAssignment stores val as element of array or hash
+func (e *Evaluator) evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := object.ClampInt(index)
+		if !ok {
+			return newError("index of ARRAY must be INTEGER, got %s", index.Type())
+		}
+		if idx < 0 || idx >= int64(len(left.Elements)) {
+			return newError("index out of range: %s", index.Inspect())
+		}
+		left.Elements[idx] = val
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		hashed := key.HashKey()
+		if _, ok := left.Pairs[hashed]; !ok {
+			if err := e.allocBytes(hashPairSize); err != nil {
+				return err
+			}
+		}
+		left.Set(hashed, object.HashPair{Key: index, Value: val})
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+	return val
+}
+
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -290,10 +616,16 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
-	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
+	case left.Type() == object.ArrayObj:
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HashObj:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.StringObj:
+		idx, ok := object.ClampInt(index)
+		if !ok {
+			return newError("index of STRING must be INTEGER, got %s", index.Type())
+		}
+		return object.CharAt(left.(*object.String).Value, idx)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -301,7 +633,10 @@ func evalIndexExpression(left, index object.Object) object.Object {
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
+	idx, ok := object.ClampInt(index)
+	if !ok {
+		return newError("index of ARRAY must be INTEGER, got %s", index.Type())
+	}
 	max := int64(len(arrayObject.Elements) - 1)
 	if idx < 0 || idx > max {
 		return Null
@@ -309,12 +644,12 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash(len(node.Pairs))
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key) {
+	for _, pair := range node.Pairs {
+		key := e.Eval(pair.Key, env)
+		if isAbrupt(key) {
 			return key
 		}
 		hashKey, ok := key.(object.Hashable)
@@ -322,16 +657,15 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := e.Eval(pair.Value, env)
+		if isAbrupt(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return e.alloc(hash)
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
@@ -350,34 +684,163 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return pair.Value
 }
 
-func evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
-	condition := Eval(we.Condition, env)
-	if isError(condition) {
-		return condition
+// evalWhileExpression evaluates loop to value of last evaluated body (or null).
+// Error and return value in condition or body stop the loop and are returned.
+func (e *Evaluator) evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	var res object.Object
+	res = Null
+	for {
+		condition := e.Eval(we.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if !isTruthry(condition) {
+			return res
+		}
+
+		var next bool
+		if res, next = e.evalLoopBody(we.Consequence, env); !next {
+			return res
+		}
+	}
+}
+
+// evalForExpression evaluates C-style 'for' loop like 'while' loop
+func (e *Evaluator) evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	if fe.Init != nil {
+		if init := e.Eval(fe.Init, env); isAbrupt(init) {
+			return init
+		}
 	}
 
 	var res object.Object
 	res = Null
-	for isTruthry(condition) {
-		res = Eval(we.Consequence, env)
-		condition = Eval(we.Condition, env)
+	for {
+		if fe.Condition != nil {
+			condition := e.Eval(fe.Condition, env)
+			if isAbrupt(condition) {
+				return condition
+			}
+			if !isTruthry(condition) {
+				return res
+			}
+		}
+
+		var next bool
+		if res, next = e.evalLoopBody(fe.Body, env); !next {
+			return res
+		}
+
+		if fe.Post != nil {
+			if post := e.Eval(fe.Post, env); isAbrupt(post) {
+				return post
+			}
+		}
 	}
-	return res
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalForInExpression evaluates 'for ... in' loop binding loop variables in env
+func (e *Evaluator) evalForInExpression(fe *ast.ForInExpression, env *object.Environment) object.Object {
+	iterable := e.Eval(fe.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+	it := object.Iterate(iterable)
+	if it == nil {
+		return newError("not iterable: %s", iterable.Type())
+	}
+
+	var res object.Object
+	res = Null
+	for {
+		key, value, ok := it.Next()
+		if !ok {
+			return res
+		}
+		if len(fe.Variables) == 2 {
+			env.Set(fe.Variables[0].Value, key)
+			env.Set(fe.Variables[1].Value, value)
+		} else if it.Keyed {
+			env.Set(fe.Variables[0].Value, key)
+		} else {
+			env.Set(fe.Variables[0].Value, value)
+		}
+
+		var next bool
+		if res, next = e.evalLoopBody(fe.Body, env); !next {
+			return res
+		}
+	}
+}
+
+// evalLoopBody evaluates body of loop and returns its value (null if it has no value).
+// It reports false if loop must stop and return the value ('break', 'return' or error).
+func (e *Evaluator) evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	res := e.Eval(body, env)
+	switch res.(type) {
+	case *object.Error, *object.ReturnValue:
+		return res, false
+	case *object.Break:
+		return Null, false
+	case *object.Continue, nil:
+		return Null, true
+	}
+	return res, true
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object, pos token.Position) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d",
+				len(fn.Parameters), len(args))
+		}
+		if e.depth >= e.limits.MaxCallDepth {
+			return e.stop(ErrCallDepthExceeded)
+		}
+		if err := e.allocBytes(envSize + elementSize*int64(len(args))); err != nil {
+			return err
+		}
+		e.depth++
+		defer func() { e.depth-- }()
+
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := e.Eval(fn.Body, extendedEnv)
+		if err, ok := evaluated.(*object.Error); ok {
+			err.Stack = append(err.Stack, object.Frame{Function: fn.FunctionName(), Pos: pos})
+		}
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return e.alloc(fn.Call(&builtinRuntime{e: e, pos: pos}, args...))
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// builtinRuntime lets builtin function call functions given as its arguments
+type builtinRuntime struct {
+	e   *Evaluator
+	pos token.Position // Position of calling builtin function
+}
+
+// Apply calls fn with args as if it is called at position of builtin function call
+func (rt *builtinRuntime) Apply(fn object.Object, args ...object.Object) object.Object {
+	return rt.e.applyFunction(fn, args, rt.pos)
+}
+
+// IO returns standard input and outputs set to evaluator
+func (rt *builtinRuntime) IO() *object.IO {
+	return rt.e.io
+}
+
+// CheckAlloc returns error if allocating size bytes exceeds allocation limit of evaluator
+func (rt *builtinRuntime) CheckAlloc(size int64) object.Object {
+	if err := rt.e.checkAlloc(size); err != nil {
+		return err
+	}
+	return nil
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
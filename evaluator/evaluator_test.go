@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/x-color/monkey/lexer"
+	"github.com/x-color/monkey/object"
+	"github.com/x-color/monkey/parser"
+)
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + c
+};
+let calc = fn(x) {
+  add(x, 1) * 2
+};
+calc(3);`
+
+	l := lexer.NewWithFilename("test.mky", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %q", p.Errors())
+	}
+
+	evaluated := Eval(program, object.NewEnvironment())
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := "ERROR: identifier not found: c\n" +
+		"\tat add (test.mky:2:7)\n" +
+		"\tat calc (test.mky:5:3)\n" +
+		"\tat <main> (test.mky:7:1)\n"
+	if err.StackTrace() != expected {
+		t.Errorf("wrong stack trace.\nwant=%q\ngot=%q", expected, err.StackTrace())
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+		expectedEnd string
+	}{
+		{"5 + true;", "1:1", "1:9"},
+		{"let x = 1;\n-true", "2:1", "2:6"},
+		{"len(1)", "1:1", "1:7"},
+		{"if (10 > 1) {\n  foobar\n}", "2:3", "2:9"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		evaluated := Eval(program, object.NewEnvironment())
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if err.Pos.String() != tt.expectedPos || err.End.String() != tt.expectedEnd {
+			t.Errorf("%q: wrong position. want=%s-%s, got=%s-%s",
+				tt.input, tt.expectedPos, tt.expectedEnd, err.Pos, err.End)
+		}
+	}
+}
+
+func TestWhileExpressionStops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{} // int64 or error message
+	}{
+		{"while (true) { 1 + true; }", "type mismatch: INTEGER + BOOLEAN"},
+		{"let i = 0; while (i < 10) { i += 1; if (i == 3) { foo } } i", "identifier not found: foo"},
+		{"let i = 0; while (if (i < 3) { true } else { i + true }) { i += 1 }", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() { let x = 1; while (true) { return x; } }; f()", int64(1)},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 5) { return i * 10; } } }; f() + 1", int64(51)},
+		{"let f = fn() { while (true) { while (true) { return 7; } } }; f()", int64(7)},
+		{"let i = 0; while (true) { i += 1; if (i == 4) { return i; } } 100", int64(4)},
+	}
+
+	for _, tt := range tests {
+		// Steps are limited so that broken loop fails instead of running forever
+		evaluated := evalWithLimits(t, context.Background(), tt.input, Limits{MaxSteps: 100000})
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			testIntegerObject(t, evaluated, expected)
+		case string:
+			err, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if err.Message != expected {
+				t.Errorf("%q: wrong error message. want=%q, got=%q", tt.input, expected, err.Message)
+			}
+		}
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("boom", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		panic("boom")
+	}})
+
+	l := lexer.New("let f = fn() { boom() }; f()")
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	e := New(context.Background(), Limits{})
+	evaluated := e.Eval(program, env)
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if err.Message != "internal error: boom" {
+		t.Errorf("wrong error message. want=%q, got=%q", "internal error: boom", err.Message)
+	}
+	if err.Pos.Line != 1 || err.Pos.Column != 16 {
+		t.Errorf("wrong error position. want=1:16, got=%d:%d", err.Pos.Line, err.Pos.Column)
+	}
+
+	// Evaluator can be used after panic
+	l = lexer.New("1 + 2")
+	p = parser.New(l)
+	testIntegerObject(t, e.Eval(p.ParseProgram(), env), 3)
+
+	fn, _ := env.Get("f")
+	if result := e.Apply(fn); result.Inspect() != "ERROR: internal error: boom" {
+		t.Errorf("wrong result of Apply. got=%s", result.Inspect())
+	}
+}
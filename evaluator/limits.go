@@ -0,0 +1,110 @@
+package evaluator
+
+import (
+	"errors"
+
+	"github.com/x-color/monkey/object"
+)
+
+// Errors causing evaluation to stop.
+// Error object returned on exceeded limit has one of them (or ctx.Err()) as Cause.
+var (
+	ErrStepLimitExceeded  = errors.New("step limit exceeded")
+	ErrCallDepthExceeded  = errors.New("call depth limit exceeded")
+	ErrAllocLimitExceeded = errors.New("allocation limit exceeded")
+)
+
+// Limits restricts resources used by evaluation. Zero value means no limit,
+// except that zero MaxCallDepth means DefaultMaxCallDepth.
+type Limits struct {
+	MaxSteps     int64 // Maximum number of evaluated AST nodes
+	MaxCallDepth int   // Maximum depth of nested function calls
+	MaxAlloc     int64 // Approximate maximum bytes allocated for objects and environments
+}
+
+// DefaultMaxCallDepth is depth of nested function calls allowed if MaxCallDepth is not set.
+// Unlimited recursion would overflow Go stack, which cannot be recovered.
+const DefaultMaxCallDepth = 10000
+
+// checkContextInterval is number of steps between checks of context cancellation
+const checkContextInterval = 256
+
+// Approximate sizes of objects in bytes
+const (
+	objectSize   = 16
+	elementSize  = 16
+	hashPairSize = 64
+	envSize      = 64
+)
+
+// step counts evaluation step and checks limits and context
+func (e *Evaluator) step() *object.Error {
+	if e.stopped != nil {
+		return e.stopError()
+	}
+
+	e.steps++
+	if e.limits.MaxSteps > 0 && e.steps > e.limits.MaxSteps {
+		return e.stop(ErrStepLimitExceeded)
+	}
+	if e.steps%checkContextInterval == 0 {
+		if err := e.ctx.Err(); err != nil {
+			return e.stop(err)
+		}
+	}
+	return nil
+}
+
+// alloc counts allocated bytes of obj and returns obj or error on exceeded limit
+func (e *Evaluator) alloc(obj object.Object) object.Object {
+	if err := e.allocBytes(sizeOf(obj)); err != nil {
+		return err
+	}
+	return obj
+}
+
+func (e *Evaluator) allocBytes(n int64) *object.Error {
+	e.allocated += n
+	if e.limits.MaxAlloc > 0 && e.allocated > e.limits.MaxAlloc {
+		return e.stop(ErrAllocLimitExceeded)
+	}
+	return nil
+}
+
+// checkAlloc returns error if allocating n bytes would exceed limit.
+// Unlike allocBytes, n is not counted.
+func (e *Evaluator) checkAlloc(n int64) *object.Error {
+	if e.limits.MaxAlloc > 0 && e.allocated+n > e.limits.MaxAlloc {
+		return e.stop(ErrAllocLimitExceeded)
+	}
+	return nil
+}
+
+// stop stops evaluation. Following steps fail with the same cause.
+func (e *Evaluator) stop(cause error) *object.Error {
+	e.stopped = cause
+	return e.stopError()
+}
+
+func (e *Evaluator) stopError() *object.Error {
+	return &object.Error{Message: e.stopped.Error(), Cause: e.stopped}
+}
+
+// sizeOf returns approximate size of obj excluding objects referred by it
+func sizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.Boolean, *object.Null, *object.Error, nil:
+		// Shared constants and errors are not counted
+		return 0
+	case *object.String:
+		return objectSize + int64(len(obj.Value))
+	case *object.BigInt:
+		return objectSize + int64(len(obj.Value.Bits()))*8
+	case *object.Array:
+		return objectSize + elementSize*int64(len(obj.Elements))
+	case *object.Hash:
+		return objectSize + hashPairSize*int64(len(obj.Pairs))
+	default:
+		return objectSize
+	}
+}
@@ -0,0 +1,101 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/x-color/monkey/lexer"
+	"github.com/x-color/monkey/object"
+	"github.com/x-color/monkey/parser"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected error
+	}{
+		{"while (true) { }", Limits{MaxSteps: 1000}, ErrStepLimitExceeded},
+		{"let f = fn() { while (true) { 1 } }; f()", Limits{MaxSteps: 1000}, ErrStepLimitExceeded},
+		{"let f = fn(n) { f(n + 1) }; f(0)", Limits{MaxCallDepth: 100}, ErrCallDepthExceeded},
+		{`let f = fn(s) { f(s + "abcdefgh") }; f("")`, Limits{MaxAlloc: 10000}, ErrAllocLimitExceeded},
+		{"let a = [1]; while (true) { let a = push(a, 1) }", Limits{MaxAlloc: 10000}, ErrAllocLimitExceeded},
+		{`"x" * 500000000`, Limits{MaxAlloc: 10000}, ErrAllocLimitExceeded},
+		{`let s = "x"; s *= 500000000`, Limits{MaxAlloc: 10000}, ErrAllocLimitExceeded},
+		{`repeat("x", 500000000)`, Limits{MaxAlloc: 10000}, ErrAllocLimitExceeded},
+		{`padLeft("x", 500000000)`, Limits{MaxAlloc: 10000}, ErrAllocLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		evaluated := evalWithLimits(t, context.Background(), tt.input, tt.limits)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%q: wrong cause. want=%v, got=%v", tt.input, tt.expected, err.Cause)
+		}
+		if err.Message != tt.expected.Error() {
+			t.Errorf("%q: wrong message. got=%q", tt.input, err.Message)
+		}
+	}
+}
+
+func TestLimitsNotExceeded(t *testing.T) {
+	input := "let f = fn(n) { if (n == 0) { 0 } else { n + f(n - 1) } }; f(50)"
+	limits := Limits{MaxSteps: 100000, MaxCallDepth: 51, MaxAlloc: 100000}
+
+	evaluated := evalWithLimits(t, context.Background(), input, limits)
+	testIntegerObject(t, evaluated, 1275)
+}
+
+func TestLimitsPadString(t *testing.T) {
+	// Only padding cut at width is allocated even if pad string is long
+	input := `let pad = "ab" * 1000; len(padLeft("x", 3000, pad))`
+	limits := Limits{MaxAlloc: 10000}
+
+	evaluated := evalWithLimits(t, context.Background(), input, limits)
+	testIntegerObject(t, evaluated, 3000)
+}
+
+func TestContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	evaluated := evalWithLimits(t, ctx, "while (true) { }", Limits{})
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wrong cause. want=%v, got=%v", context.DeadlineExceeded, err.Cause)
+	}
+}
+
+func evalWithLimits(t *testing.T, ctx context.Context, input string, limits Limits) object.Object {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %q", p.Errors())
+	}
+
+	return New(ctx, limits).Eval(program, object.NewEnvironment())
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Fatalf("object is not Integer. got=%T (%+v)", obj, obj)
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+	}
+}
@@ -0,0 +1,720 @@
+package exec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/x-color/monkey/ast"
+	"github.com/x-color/monkey/lexer"
+	"github.com/x-color/monkey/object"
+	"github.com/x-color/monkey/parser"
+)
+
+var engines = []Engine{Evaluator, VM}
+
+// conformanceTests are programs which every engine must execute with same result.
+// Expected value is Inspect() of result (” if program has no value).
+var conformanceTests = []struct {
+	input    string
+	expected string
+}{
+	// integers and booleans
+	{"5", "5"},
+	{"-10", "-10"},
+	{"5 + 5 + 5 + 5 - 10", "10"},
+	{"2 * (5 + 10) / 3", "10"},
+	{"50 / 2 * 2 + 10 - 5", "55"},
+	{"-50 + 100 + -50", "0"},
+	{"true", "true"},
+	{"1 < 2", "true"},
+	{"1 > 2", "false"},
+	{"1 == 1", "true"},
+	{"1 != 1", "false"},
+	{"true == false", "false"},
+	{"(1 < 2) == true", "true"},
+	{"!true", "false"},
+	{"!5", "false"},
+	{"!!5", "true"},
+	{"!(if (false) { 5; })", "true"},
+
+	// floats
+	{"3.14", "3.14"},
+	{"1e-9", "1e-09"},
+	{"2.5e3", "2500.0"},
+	{"-1.5", "-1.5"},
+	{"1.5 + 1.5", "3.0"},
+	{"1 + 0.5", "1.5"},
+	{"0.5 * 4", "2.0"},
+	{"7 / 2.0", "3.5"},
+	{"1.0 / 0", "+Inf"},
+	{"1 < 1.5", "true"},
+	{"2.0 > 3", "false"},
+	{"1 == 1.0", "true"},
+	{"0.1 + 0.2 != 0.3", "true"},
+	{`{1: "one"}[1.0]`, "one"},
+	{`{1.5: "x"}[1.5]`, "x"},
+	{"int(3.9)", "3"},
+	{"int(-3.9)", "-3"},
+	{`int("42")`, "42"},
+	{"float(3)", "3.0"},
+	{`float("2.5")`, "2.5"},
+	{"sqrt(16)", "4.0"},
+	{"floor(2.7)", "2.0"},
+	{"ceil(2.1)", "3.0"},
+	{"floor(2)", "2"},
+	{"pow(2, 10)", "1024"},
+	{"pow(2, -1)", "0.5"},
+	{"pow(4, 0.5)", "2.0"},
+	{"abs(-3)", "3"},
+	{"abs(-2.5)", "2.5"},
+
+	// modulo, exponent and comparison
+	{"7 % 3", "1"},
+	{"-7 % 3", "-1"},
+	{"7.5 % 2", "1.5"},
+	{"2 ** 10", "1024"},
+	{"2 ** 3 ** 2", "512"},
+	{"-2 ** 2", "-4"},
+	{"2 ** -1", "0.5"},
+	{"4 ** 0.5", "2.0"},
+	{"1 + 2 * 3 % 4", "3"},
+	{"1 <= 2", "true"},
+	{"2 <= 2", "true"},
+	{"3 <= 2", "false"},
+	{"1 >= 2", "false"},
+	{"2 >= 2", "true"},
+	{"1.5 >= 1", "true"},
+	{"1 <= 0.5", "false"},
+	{"true >= false", "ERROR: unknown operator: BOOLEAN >= BOOLEAN"},
+	{"1 % true", "ERROR: type mismatch: INTEGER % BOOLEAN"},
+
+	// bitwise operators
+	{"12 & 10", "8"},
+	{"12 | 10", "14"},
+	{"12 ^ 10", "6"},
+	{"~5", "-6"},
+	{"1 << 4", "16"},
+	{"-16 >> 2", "-4"},
+	{"1 << 64", "18446744073709551616"},
+	{"let flags = 13; (flags & (1 << 2)) != 0", "true"},
+	{"let flags = 13; flags & 1 << 2 != 0", "ERROR: type mismatch: INTEGER & BOOLEAN"},
+	{"1 | 2 ^ 3 & 4", "3"},
+	{"1 << -1", "ERROR: negative shift count: 1 << -1"},
+	{"8 >> -2", "ERROR: negative shift count: 8 >> -2"},
+	{"1.0 & 1", "ERROR: unknown operator: FLOAT & INTEGER"},
+	{"~1.5", "ERROR: unknown operator: ~FLOAT"},
+	{"~true", "ERROR: unknown operator: ~BOOLEAN"},
+	{"true | false", "ERROR: unknown operator: BOOLEAN | BOOLEAN"},
+
+	// comments
+	{"1 // comment", "1"},
+	{"/* comment */ 2", "2"},
+	{"let a = 10; /* 5 */ a / 2 // half", "5"},
+
+	// strings
+	{`"monkey"`, "monkey"},
+	{`"mon" + "key" + "banana"`, "monkeybanana"},
+	{`"tab\there"`, "tab\there"},
+	{`len("a\nb")`, "3"},
+	{`"say \"hi\""`, `say "hi"`},
+	{`"\u{41}\u{42}"`, "AB"},
+	{"`raw\n\\n`", "raw\n\\n"},
+	{`"abc" == "abc"`, "true"},
+	{`"abc" == "abd"`, "false"},
+	{`"abc" != "abd"`, "true"},
+	{`"a" + "bc" == "ab" + "c"`, "true"},
+	{`"abc" < "abd"`, "true"},
+	{`"b" > "abc"`, "true"},
+	{`"ab" <= "ab"`, "true"},
+	{`"ab" >= "abc"`, "false"},
+
+	// logical operators
+	{"true && true", "true"},
+	{"true && false", "false"},
+	{"false || true", "true"},
+	{"false || false", "false"},
+	{"1 && 2", "true"},
+	{"0 || null", "true"},
+	{"if (false) { 1 } || if (false) { 1 }", "false"},
+	{"1 < 2 && 2 < 3", "true"},
+	{"1 > 2 || 2 > 3", "false"},
+	{"true || false && false", "true"},
+	{"(true || false) && false", "false"},
+	{"false && undefinedName", "false"},
+	{"true || undefinedName", "true"},
+	{"true && undefinedName", "ERROR: identifier not found: undefinedName"},
+	{"let f = fn() { 1 + true }; false && f()", "false"},
+	{"let f = fn() { 1 + true }; true || f()", "true"},
+	{"let x = 5; x > 0 && x < 10 || x == 100", "true"},
+
+	// conditionals
+	{"if (true) { 10 }", "10"},
+	{"if (1) { 10 }", "10"},
+	{"if (1 > 2) { 10 }", "null"},
+	{"if (1 > 2) { 10 } else { 20 }", "20"},
+	{"if ((if (false) { 10 })) { 10 } else { 20 }", "20"},
+
+	// let and global variables
+	{"let one = 1; one", "1"},
+	{"let one = 1; let two = one + one; one + two", "3"},
+	{"let a = 1;", ""},
+	{"let a = 1; let a = a + 1; a", "2"},
+
+	// assignment
+	{"let x = 1; x = 2; x", "2"},
+	{"let x = 1; x = x + 1", "2"},
+	{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4; x", "2"},
+	{"let a = 1; let b = 2; a = b = 3; a + b", "6"},
+	{"let s = \"mon\"; s += \"key\"; s", "monkey"},
+	{"let i = 0; let f = fn() { i = i + 1; }; f(); f(); i", "2"},
+	{"let f = fn() { let n = 0; fn() { n += 1 } }; let c = f(); c(); c()", "2"},
+	{"let f = fn() { let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n }; f()", "2"},
+	{"let f = fn(x) { x = x * 2; x }; f(4)", "8"},
+	{"let sum = 0; let i = 0; while (i < 5) { i += 1; sum += i; } sum", "15"},
+	{"let arr = [1, 2, 3]; arr[0] = 10; arr[2] += 5; arr", "[10,2,8]"},
+	{"let arr = [1, 2, 3]; let alias = arr; alias[1] = 0; arr", "[1,0,3]"},
+	{`let h = {"a": 1}; h["a"] += 1; h["b"] = 5; h["a"] + h["b"]`, "7"},
+	{"let m = [[1, 2], [3, 4]]; m[1][0] = 9; m", "[[1,2],[9,4]]"},
+	{"let a = [1, 2]; a[0] = a; a", "[[...],2]"},
+	{`let h = {"a": 1}; h["self"] = h; h`, "{a: 1, self: {...}}"},
+	{`let a = [1]; let h = {"a": a}; a[0] = h; h`, "{a: [{...}]}"},
+	{"let b = [1]; [b, b]", "[[1],[1]]"},
+	{"let arr = [1]; arr[0] = 2", "2"},
+	{"y = 1", "ERROR: identifier not found: y"},
+	{"let f = fn() { z = 1 }; f()", "ERROR: identifier not found: z"},
+	{"y += 1", "ERROR: identifier not found: y"},
+	{"len = 1", "ERROR: cannot assign to builtin function: len"},
+	{"len += 1", "ERROR: cannot assign to builtin function: len"},
+	{"if (false) { len = 3; }; 1", "1"},
+	{"let len = 1; len = 2; len", "2"},
+	{"let arr = [1]; arr[1] = 2", "ERROR: index out of range: 1"},
+	{"let arr = [1]; arr[-1] = 2", "ERROR: index out of range: -1"},
+	{`let arr = [1]; arr["a"] = 2`, "ERROR: index of ARRAY must be INTEGER, got STRING"},
+	{"let h = {}; h[[1]] = 2", "ERROR: unusable as hash key: ARRAY"},
+	{`let s = "abc"; s[0] = "x"`, "ERROR: index assignment not supported: STRING"},
+	{"let x = 1; x += true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+
+	// while
+	{"let i = 0; while (i < 5) { let i = i + 1; } i", "5"},
+	{"while (false) { 1 }", "null"},
+	{"let i = 0; while (i < 3) { let i = i + 1; i * 10 }", "30"},
+	{"let f = fn() { let x = 1; while (true) { return x; } }; f()", "1"},
+	{"let f = fn() { let i = 0; while (i < 10) { i += 1; if (i == 3) { return i; } } 0 }; f()", "3"},
+	{"let i = 0; while (i < 3) { i += 1; i + true }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	{"let i = 0; while (if (i < 2) { true } else { i + true }) { i += 1 }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+
+	// break and continue
+	{"let i = 0; while (true) { i += 1; if (i == 5) { break; } } i", "5"},
+	{"let i = 0; while (i < 10) { i += 1; if (i > 2) { break } i }", "null"},
+	{"let i = 0; let s = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue; } s += i; } s", "25"},
+	{"let i = 0; while (i < 3) { i += 1; if (i == 3) { continue } i }", "null"},
+	{"let i = 0; while (i < 3) { i += 1; if (i == 2) { continue } i }", "3"},
+	{`let n = 0; let i = 0;
+	while (i < 3) {
+		i += 1;
+		let j = 0;
+		while (true) { j += 1; if (j > i) { break; } n += 1; }
+	}
+	n`, "6"},
+	{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 3) { break; } } i * 2 }; f()", "6"},
+	{"let f = fn() { let n = 0; while (n < 5) { n += 1; let g = fn() { n }; if (g() < 5) { continue } } n }; f()", "5"},
+	{"let x = 0; while (x < 10) { x += 1; let y = if (x == 3) { break; }; } x", "3"},
+	{"let n = 0; let i = 0; while (i < 5) { i += 1; n = n + if (i == 2) { continue; } else { i }; } n", "13"},
+	{"let s = 0; for (i in range(5)) { s += [if (i % 2 == 0) { continue; } else { i }][0]; } s", "4"},
+	{`let n = 0; while (true) { n += 1; let h = {"a": if (n == 2) { break; } else { 1 }}; } n`, "2"},
+	{"let g = fn(x) { x }; let n = 0; while (true) { n += 1; g(if (n == 3) { break; } else { n }); } n", "3"},
+	{"let n = 0; while (true) { n += 1; let x = -if (n == 4) { break; } else { n }; } n", "4"},
+	{`
+let f = fn(a, b) { a + b };
+let i = 0;
+let n = 0;
+while (true) {
+	i += 1;
+	let x = f(n, 1 + if (i % 2 == 0) { continue; } else { 1 });
+	n = f(x, [i, if (i > 6) { break; } else { 0 }][1]);
+}
+f(n, i * 100)`, "706"},
+	{"let y = if (true) { return 1; }; 2", "1"},
+	{"fn() {}()", "null"},
+	{"fn() { let x = 1; }()", "null"},
+	{"let y = if (true) {}; y", "null"},
+	{"let y = if (true) { let x = 1; }; y", "null"},
+	{"let w = while (false) {}; w", "null"},
+	{"let w = for (x in []) {}; w", "null"},
+	{"[fn() {}(), if (true) {}]", "[null,null]"},
+	{"let f = fn() { let y = if (true) { return 1; }; 2 }; f()", "1"},
+	{"let f = fn() { [1, if (true) { return 2; }, 3] }; f()", "2"},
+	{`let f = fn() {
+	let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+	let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+	isEven(10)
+};
+f()`, "true"},
+	{"let f = fn() { let g = fn() { x }; let x = 5; g() }; f()", "5"},
+	{"let f = fn() { let g = fn() { x = 3 }; let x = 5; g(); x }; f()", "3"},
+	{"let f = fn() { fn() { fn() { x } }()() }; let x = 7; f()", "7"},
+	{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(2000)", "2001000"},
+	{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(9000)", "40504500"},
+	{`let f = fn() {
+	let x = 1;
+	let inc = fn() { x += 1 };
+	let deep = fn(n) { if (n == 0) { inc() } else { deep(n - 1) } };
+	deep(3000);
+	x
+};
+f()`, "2"},
+
+	// for
+	{"let s = 0; for (let i = 1; i <= 10; i += 1) { s += i; } s", "55"},
+	{"for (let i = 0; i < 3; i += 1) { i * 10 }", "20"},
+	{"for (let i = 0; false; i += 1) { 1 }", "null"},
+	{"let s = 0; for (let i = 0; i < 10; i += 1) { if (i % 2 == 0) { continue } s += i; } s", "25"},
+	{"let i = 0; for (; ; i += 1) { if (i == 4) { break } } i", "4"},
+	{"let n = 0; for (let i = 0; i < 3; i += 1) { for (let j = 0; j < 3; j += 1) { if (j > i) { break } n += 1; } } n", "6"},
+	{"let f = fn(n) { for (let i = 0; i < n; i += 1) { if (i * i > n) { return i } } 0 }; f(10)", "4"},
+	{"for (let i = 0; i < 3; i += 1) { i + true }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+
+	// for ... in
+	{"let s = 0; for (x in [1, 2, 3]) { s += x; } s", "6"},
+	{"let s = 0; for (i, x in [10, 20, 30]) { s += i * x; } s", "80"},
+	{"let s = \"\"; for (k in {\"a\": 1}) { s += k; } s", "a"},
+	{"let s = 0; for (k, v in {\"a\": 1, \"b\": 2}) { s += v; } s", "3"},
+	{"let s = \"\"; for (c in \"abc\") { s = c + s; } s", "cba"},
+	{"let s = 0; for (i in range(5)) { s += i; } s", "10"},
+	{"let a = []; for (i in range(1, 10, 3)) { a = push(a, i); } a", "[1,4,7]"},
+	{"let a = []; for (i in range(3, 0, -1)) { a = push(a, i); } a", "[3,2,1]"},
+	{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue } if (x == 4) { break } s += x; } s", "4"},
+	{"let n = 0; for (x in range(3)) { for (y in range(3)) { n += 1; } } n", "9"},
+	{"let f = fn(a) { for (i, x in a) { if (x == 3) { return i } } return -1; }; f([5, 4, 3])", "2"},
+	{"for (x in []) { x }", "null"},
+	{"for (x in 1) { x }", "ERROR: not iterable: INTEGER"},
+	{"range(5)", "range(0, 5, 1)"},
+	{"len(range(0, 10, 3))", "4"},
+	{"len(range(5, 0))", "0"},
+	{"range(0, 5, 0)", "ERROR: step of `range` must not be zero"},
+
+	// functions
+	{"let f = fn() { 5 + 10; }; f()", "15"},
+	{"let f = fn() { return 99; 100; }; f()", "99"},
+	{"let f = fn() { if (true) { return 1; } return 2; }; f()", "1"},
+	{"let add = fn(a, b) { a + b }; add(1, add(2, 3))", "6"},
+	{"fn(x) { x * 2 }(3)", "6"},
+	{"let f = fn(a) { let b = a * 2; let b = b + 1; b }; f(5)", "11"},
+	{"return 10; 9", "10"},
+
+	// closures
+	{"let newAdder = fn(x) { fn(y) { x + y } }; newAdder(2)(3)", "5"},
+	{"let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", "6"},
+	{`let f = fn() {
+		let x = 1;
+		let g = fn() { x };
+		let x = 2;
+		g()
+	};
+	f()`, "2"},
+	{`let counter = fn() {
+		let n = 0;
+		let inc = fn() { n + 1 };
+		let read = fn() { n };
+		[inc, read]
+	};
+	let fs = counter();
+	fs[0]() + fs[1]()`, "1"},
+	{"let g = fn() { h() }; let h = fn() { 7 }; g()", "7"},
+
+	// recursion
+	{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)", "610"},
+	{`let wrapper = fn() {
+		let countDown = fn(x) { if (x == 0) { return 0; } countDown(x - 1) };
+		countDown(10)
+	};
+	wrapper()`, "0"},
+
+	// arrays and hashes
+	{"[1, 2 * 2, 3 + 3]", "[1,4,6]"},
+	{"[1, 2, 3][1]", "2"},
+	{"[1, 2, 3][1 + 1]", "3"},
+	{"[1, 2, 3][3]", "null"},
+	{"[1, 2, 3][-1]", "null"},
+	{`{"one": 1}["one"]`, "1"},
+	{`{"one": 1}["two"]`, "null"},
+	{`{"b": 1, "a": 2, "c": 3}`, "{b: 1, a: 2, c: 3}"},
+	{`{"z": 1, 2: 2, true: 3}`, "{z: 1, 2: 2, true: 3}"},
+	{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+	{`let h = {"b": 1, "a": 2}; h["c"] = 3; h["b"] = 4; h`, "{b: 4, a: 2, c: 3}"},
+	{`let s = ""; let h = {"b": s += "b", "a": s += "a"}; s`, "ba"},
+	{`let a = []; for (k, v in {"z": 1, "x": 2, "y": 3}) { a = push(a, k); } a`, "[z,x,y]"},
+	{`{1: true}[1]`, "true"},
+	{`{true: 5}[true]`, "5"},
+	{`let key = "k"; {key: 1 + 1}["k"]`, "2"},
+
+	// builtins
+	{`len("")`, "0"},
+	{`len("four")`, "4"},
+	{`len([1, 2, 3])`, "3"},
+	{`len("日本")`, "2"},
+	{`bytelen("日本")`, "6"},
+	{`bytelen("abc")`, "3"},
+	{`let 名前 = "monkey"; 名前`, "monkey"},
+	{`let café = "☕"; café + "!"`, "☕!"},
+	{`first([1, 2, 3])`, "1"},
+	{`first([])`, "null"},
+	{`last([1, 2, 3])`, "3"},
+	{`rest([1, 2, 3])`, "[2,3]"},
+	{`push([1], 2)`, "[1,2]"},
+	{`let len = fn(x) { 42 }; len("a")`, "42"},
+	{`!first([])`, "true"},
+
+	// hash builtins
+	{`len({"a": 1, "b": 2})`, "2"},
+	{`len({})`, "0"},
+	{`keys({"b": 1, "a": 2})`, "[b,a]"},
+	{`values({"b": 1, "a": 2})`, "[1,2]"},
+	{`items({"b": 1, 2: true})`, "[[b,1],[2,true]]"},
+	{`has({"a": 1}, "a")`, "true"},
+	{`has({"a": 1}, "b")`, "false"},
+	{`has({1: 1}, 1.0)`, "true"},
+	{`let h = {"a": 1, "b": 2, "c": 3}; let d = delete(h, "b"); [d, h]`, "[{a: 1, c: 3},{a: 1, b: 2, c: 3}]"},
+	{`delete({"a": 1}, "z")`, "{a: 1}"},
+	{`let h = {"a": 1, "b": 2}; let m = merge(h, {"b": 3, "c": 4}); [m, h]`, "[{a: 1, b: 3, c: 4},{a: 1, b: 2}]"},
+	{`get({"a": 1}, "a", 0)`, "1"},
+	{`get({"a": 1}, "b", 0)`, "0"},
+	{`get({"a": 1}, "b")`, "null"},
+	{`let h = {}; h = merge(h, {"x": 1}); h["x"] += 1; h`, "{x: 2}"},
+	{`keys([1])`, "ERROR: argument to `keys` must be HASH, got ARRAY"},
+	{`has({}, [1])`, "ERROR: unusable as hash key: ARRAY"},
+	{`merge({}, 1)`, "ERROR: argument to `merge` must be HASH, got INTEGER"},
+	{`get({})`, "ERROR: wrong number of arguments. got=1, want=2..3"},
+	{`delete({})`, "ERROR: wrong number of arguments. got=1, want=2"},
+
+	// array builtins
+	{`map([1, 2, 3], fn(x) { x * 2 })`, "[2,4,6]"},
+	{`map([], fn(x) { x })`, "[]"},
+	{`map(["a", "bc"], len)`, "[1,2]"},
+	{`let k = 10; map([1, 2], fn(x) { x + k })`, "[11,12]"},
+	{`filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })`, "[2,4]"},
+	{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x }, 10)`, "20"},
+	{`reduce([1, 2, 3, 4], fn(acc, x) { acc * x })`, "24"},
+	{`reduce([], fn(acc, x) { acc + x }, 0)`, "0"},
+	{`reduce([], fn(acc, x) { acc + x })`, "ERROR: reduce of empty array with no initial value"},
+	{`find([1, 5, 8], fn(x) { x > 3 })`, "5"},
+	{`find([1, 2], fn(x) { x > 3 })`, "null"},
+	{`any([1, 2, 3], fn(x) { x > 2 })`, "true"},
+	{`any([], fn(x) { true })`, "false"},
+	{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+	{`all([1, 2, 3], fn(x) { x > 1 })`, "false"},
+	{`sort([3, 1.5, 2])`, "[1.5,2,3]"},
+	{`sort(["b", "c", "a"])`, "[a,b,c]"},
+	{`let a = [3, 1, 2]; sort(a, fn(x, y) { x > y }); a`, "[3,1,2]"},
+	{`sort([3, 1, 2], fn(x, y) { x > y })`, "[3,2,1]"},
+	{`sort([[2, "b"], [1, "a"], [2, "a"]], fn(x, y) { x[0] < y[0] })`, "[[1,a],[2,b],[2,a]]"},
+	{`sort([1, "a"])`, "ERROR: cannot compare STRING and INTEGER"},
+	{`sort([1, 2], fn(x, y) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	{`sort([3, 1, 2], fn(x, y) { x - y })`, "[1,2,3]"},
+	{`sort([3, 1, 2], fn(x, y) { y - x })`, "[3,2,1]"},
+	{`sort(["bb", "a", "ccc"], fn(x, y) { len(x) - len(y) })`, "[a,bb,ccc]"},
+	{`sort([1, 2], fn(x, y) { "a" })`, "ERROR: comparator of `sort` must return BOOLEAN or INTEGER, got STRING"},
+	{`sort([1, 2], fn(x, y) { })`, "ERROR: comparator of `sort` must return BOOLEAN or INTEGER, got NULL"},
+	{`reverse([1, 2, 3])`, "[3,2,1]"},
+	{`concat([1], [], [2, 3])`, "[1,2,3]"},
+	{`join([1, "a", true], ", ")`, "1, a, true"},
+	{`join(["a", "b"])`, "ab"},
+	{`indexOf([1, 2, 3], 2)`, "1"},
+	{`indexOf([[1], [2]], [2])`, "1"},
+	{`indexOf([1, 2], 5)`, "-1"},
+	{`contains([1, "a"], "a")`, "true"},
+	{`contains([1.0], 1)`, "true"},
+	{"let a = [1]; a[0] = a; contains([a], a)", "true"},
+	{"let a = [1]; a[0] = a; let b = [1]; b[0] = b; indexOf([1, b], a)", "1"},
+	{"let a = [1]; a[0] = a; len(unique([a, a, [a]]))", "1"},
+	{`let h = {}; h["h"] = h; contains([1, {"h": 2}], h)`, "false"},
+	{`contains([{"a": 1}], {"a": 2})`, "false"},
+	{`slice([1, 2, 3, 4], 1, 3)`, "[2,3]"},
+	{`slice([1, 2, 3, 4], 2)`, "[3,4]"},
+	{`slice([1, 2, 3, 4], -2)`, "[3,4]"},
+	{`slice([1, 2, 3], 2, 1)`, "[]"},
+	{`slice([1, 2, 3], 0, 10)`, "[1,2,3]"},
+	{`zip([1, 2, 3], ["a", "b"])`, "[[1,a],[2,b]]"},
+	{`flatten([1, [2, [3]], []])`, "[1,2,[3]]"},
+	{`unique([1, 2, 1, "a", "a", [1], [1], 1.0])`, "[1,2,a,[1]]"},
+	{`map(1, fn(x) { x })`, "ERROR: argument to `map` must be ARRAY, got INTEGER"},
+	{`map([1], 1)`, "ERROR: not a function: INTEGER"},
+	{`map([1, 2], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	{`let f = fn(x) { if (x > 1) { return x * 10 } x }; map([1, 2], f)`, "[1,20]"},
+	{`let f = fn(a) { map(a, fn(x) { map(x, fn(y) { y + 1 }) }) }; f([[1], [2, 3]])`, "[[2],[3,4]]"},
+	{`let r = fn(n) { if (n == 0) { return [] } concat([n], reduce(map([n - 1], r), fn(a, x) { x })) }; r(3)`, "[3,2,1]"},
+
+	// strings
+	{`"ab" * 3`, "ababab"},
+	{`2 * "xy"`, "xyxy"},
+	{`"ab" * 0`, ""},
+	{`let s = "-"; s *= 3; s`, "---"},
+	{`"ab" * -1`, "ERROR: negative repeat count: -1"},
+	{`"abc"[0]`, "a"},
+	{`"日本語"[1]`, "本"},
+	{`"abc"[3]`, "null"},
+	{`"abc"[-1]`, "null"},
+	{`"abc"["a"]`, "ERROR: index of STRING must be INTEGER, got STRING"},
+	{`let s = "ab"; s[0] = "x"`, "ERROR: index assignment not supported: STRING"},
+
+	// string builtins
+	{`split("a,b,,c", ",")`, "[a,b,,c]"},
+	{`split(" a  b c ")`, "[a,b,c]"},
+	{`split("日本", "")`, "[日,本]"},
+	{`join(split("a b", " "), "-")`, "a-b"},
+	{`"[" + trim("  a b \n") + "]"`, "[a b]"},
+	{`"[" + trimLeft("  a ") + "]"`, "[a ]"},
+	{`"[" + trimRight("  a ") + "]"`, "[  a]"},
+	{`upper("abc")`, "ABC"},
+	{`lower("ÀBC")`, "àbc"},
+	{`replace("a-b-c", "-", "+")`, "a+b+c"},
+	{`contains("monkey", "key")`, "true"},
+	{`contains("monkey", "x")`, "false"},
+	{`startsWith("monkey", "mon")`, "true"},
+	{`endsWith("monkey", "mon")`, "false"},
+	{`indexOf("日本語", "語")`, "2"},
+	{`indexOf("abc", "x")`, "-1"},
+	{`substring("日本語です", 1, 3)`, "本語"},
+	{`substring("hello", -3)`, "llo"},
+	{`repeat("ab", 2)`, "abab"},
+	{`padLeft("7", 3, "0")`, "007"},
+	{`padRight("ab", 5, "xy")`, "abxyx"},
+	{`padLeft("a", 4, "äbc")`, "äbca"},
+	{`padRight("a", 8, "xyz")`, "axyzxyzx"},
+	{`"[" + padLeft("abc", 2) + "]"`, "[abc]"},
+	{`"[" + padLeft("a", 3) + "]"`, "[  a]"},
+	{`chars("日本")`, "[日,本]"},
+	{`upper(1)`, "ERROR: argument to `upper` must be STRING, got INTEGER"},
+	{`contains(1, "a")`, "ERROR: argument to `contains` must be ARRAY or STRING, got INTEGER"},
+	{`indexOf("abc", 1)`, "ERROR: argument to `indexOf` must be STRING, got INTEGER"},
+	{`repeat("a", -2)`, "ERROR: negative repeat count: -2"},
+	{`padLeft("a", 3, "")`, "ERROR: pad of `padLeft` must not be empty"},
+	{`"a" * 9223372036854775807`, "ERROR: repeated string is too long"},
+
+	// big integers
+	{"9223372036854775807 + 1", "9223372036854775808"},
+	{"-9223372036854775808 - 1", "-9223372036854775809"},
+	{"9223372036854775807 * 3", "27670116110564327421"},
+	{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+	{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+	{"2 ** 64", "18446744073709551616"},
+	{"1 << 70", "1180591620717411303424"},
+	{"(1 << 70) >> 69", "2"},
+	{"123456789012345678901234567890", "123456789012345678901234567890"},
+	{"2 ** 64 - 2 ** 64 + 1", "1"},
+	{"2 ** 64 / 2 ** 32", "4294967296"},
+	{"(2 ** 64 + 5) / 2 ** 63", "2"},
+	{"-(2 ** 64 + 5) % 2 ** 63", "-5"},
+	{"~(2 ** 64)", "-18446744073709551617"},
+	{"(2 ** 64) & (2 ** 64 + 1)", "18446744073709551616"},
+	{"2 ** 64 > 9223372036854775807", "true"},
+	{"-(2 ** 64) < -9223372036854775807", "true"},
+	{"2 ** 64 == 2 ** 64", "true"},
+	{"2 ** 64 != 2 ** 65", "true"},
+	{"2 ** 64 == 18446744073709551616.0", "true"},
+	{"2 ** 64 < 1e20", "true"},
+	{"2 ** 64 + 0.5", "1.8446744073709552e+19"},
+	{"{2 ** 64: 1}[2 ** 64]", "1"},
+	{"{1e20: 1}[10 ** 20]", "1"},
+	{"{10 ** 20: 1}[1e20]", "1"},
+	{"{4: 1}[2 ** 64 / 2 ** 62]", "1"},
+	{"len({2 ** 64: 1, 18446744073709551616: 2})", "1"},
+	{"[1, 2][2 ** 64]", "null"},
+	{`"ab"[2 ** 64]`, "null"},
+	{"let a = [1]; a[2 ** 64] = 2", "ERROR: index out of range: 18446744073709551616"},
+	{"sort([2 ** 64, -1, 2 ** 63, 1.5])", "[-1,1.5,9223372036854775808,18446744073709551616]"},
+	{"unique([2 ** 64, 2 ** 64, 1])", "[18446744073709551616,1]"},
+	{"contains([2 ** 64], 18446744073709551616)", "true"},
+	{"abs(-9223372036854775807 - 1)", "9223372036854775808"},
+	{"abs(-(2 ** 64))", "18446744073709551616"},
+	{"pow(2, 64)", "18446744073709551616"},
+	{`int("18446744073709551616")`, "18446744073709551616"},
+	{"int(1e20)", "100000000000000000000"},
+	{"float(2 ** 64)", "1.8446744073709552e+19"},
+	{`"a" * (2 ** 64)`, "ERROR: repeated string is too long"},
+	{"2 ** 64 / 0", "ERROR: division by zero: 18446744073709551616 / 0"},
+	{"2 ** 100000000", "ERROR: integer overflow: 2 ** 100000000 is too large"},
+	{"let x = 2; x ** 9223372036854775807", "ERROR: integer overflow: 2 ** 9223372036854775807 is too large"},
+	{"2 << 9223372036854775807", "ERROR: integer overflow: 2 << 9223372036854775807 is too large"},
+	{"1 << -1", "ERROR: negative shift count: 1 << -1"},
+	{"range(2 ** 64)", "ERROR: argument to `range` is too large: 18446744073709551616"},
+
+	// errors
+	{"5 + true;", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	{"5 + true; 5;", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	{"-true", "ERROR: unknown operator: -BOOLEAN"},
+	{"true + false;", "ERROR: unknown operator: BOOLEAN + BOOLEAN"},
+	{`"Hello" - "World"`, "ERROR: unknown operator: STRING - STRING"},
+	{"if (10 > 1) { true + false; }", "ERROR: unknown operator: BOOLEAN + BOOLEAN"},
+	{"foobar", "ERROR: identifier not found: foobar"},
+	{"5 / 0", "ERROR: division by zero: 5 / 0"},
+	{"5 % 0", "ERROR: division by zero: 5 % 0"},
+	{"let x = 1; x /= 0", "ERROR: division by zero: 1 / 0"},
+	{"5.0 / 0", "+Inf"},
+	{"let f = fn(a, b) { a + b }; f(1)", "ERROR: wrong number of arguments: want=2, got=1"},
+	{"let f = fn() { 1 }; f(1, 2)", "ERROR: wrong number of arguments: want=0, got=2"},
+	{"map([1], fn(a, b) { a })", "ERROR: wrong number of arguments: want=2, got=1"},
+	{`[1, 2]["a"]`, "ERROR: index of ARRAY must be INTEGER, got STRING"},
+	{"[1, 2][1.0]", "ERROR: index of ARRAY must be INTEGER, got FLOAT"},
+	{"if (false) { let y = 1; } y", "ERROR: identifier not found: y"},
+	{"let f = fn() { if (false) { let y = 1; } y }; f()", "ERROR: identifier not found: y"},
+	{`{"name": "Monkey"}[fn(x) { x }];`, "ERROR: unusable as hash key: FUNCTION"},
+	{`{[1]: 2}`, "ERROR: unusable as hash key: ARRAY"},
+	{"1[0]", "ERROR: index operator not supported: INTEGER"},
+	{"1()", "ERROR: not a function: INTEGER"},
+	{`len(1)`, "ERROR: argument to 'len' not supported, got INTEGER"},
+	{`len("one", "two")`, "ERROR: wrong number of arguments. got=2, want=1"},
+	{"let f = fn() { 1 + true }; f() + 2", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	{"1.5 + true", "ERROR: type mismatch: FLOAT + BOOLEAN"},
+	{`int("1.5")`, `ERROR: could not parse "1.5" as integer`},
+	{"int(1.0 / 0)", "ERROR: cannot convert +Inf to INTEGER"},
+	{`sqrt("4")`, "ERROR: argument to `sqrt` must be INTEGER or FLOAT, got STRING"},
+}
+
+func TestConformance(t *testing.T) {
+	for _, engine := range engines {
+		for _, tt := range conformanceTests {
+			result := runProgram(t, engine, tt.input)
+
+			actual := ""
+			if result != nil {
+				actual = result.Inspect()
+			}
+			if actual != tt.expected {
+				t.Errorf("[%s] %q: wrong result. want=%q, got=%q",
+					engine, tt.input, tt.expected, actual)
+			}
+		}
+	}
+}
+
+func TestConformanceErrorStackTrace(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + c
+};
+let calc = fn(x) {
+  add(x, 1) * 2
+};
+let y = calc(3);`
+
+	expected := "ERROR: identifier not found: c\n" +
+		"\tat add (test.mky:2:7)\n" +
+		"\tat calc (test.mky:5:3)\n" +
+		"\tat <main> (test.mky:7:9)\n"
+
+	for _, engine := range engines {
+		result := runProgram(t, engine, input)
+		err, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("[%s] object is not Error. got=%T (%+v)", engine, result, result)
+			continue
+		}
+		if err.StackTrace() != expected {
+			t.Errorf("[%s] wrong stack trace.\nwant=%q\ngot=%q",
+				engine, expected, err.StackTrace())
+		}
+		if err.End.String() != "test.mky:2:8" {
+			t.Errorf("[%s] wrong error end. want=%s, got=%s",
+				engine, "test.mky:2:8", err.End)
+		}
+	}
+}
+
+func TestConformanceErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let y = 1;\nfor (x in 5) { x }", "ERROR: not iterable: INTEGER\n\tat <main> (test.mky:2:1)\n"},
+		{"let x = 1;\nlet f = fn() { len = 3 };\nf()", "ERROR: cannot assign to builtin function: len\n\tat f (test.mky:2:16)\n\tat <main> (test.mky:3:1)\n"},
+		{"let f = fn(n) {\n  if (n == 0) { x } else { f(n - 1) }\n};\nf(100)", "ERROR: identifier not found: x\n\tat f (test.mky:2:17)\n\tat f (test.mky:2:28)\n\t... previous frame repeated 99 times\n\tat <main> (test.mky:4:1)\n"},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range engines {
+			result := runProgram(t, engine, tt.input)
+			err, ok := result.(*object.Error)
+			if !ok {
+				t.Errorf("[%s] %q: object is not Error. got=%T (%+v)", engine, tt.input, result, result)
+				continue
+			}
+			if err.StackTrace() != tt.expected {
+				t.Errorf("[%s] %q: wrong stack trace.\nwant=%q\ngot=%q",
+					engine, tt.input, tt.expected, err.StackTrace())
+			}
+		}
+	}
+}
+
+func TestConformanceSession(t *testing.T) {
+	inputs := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5;", ""},
+		{"let double = fn(n) { n * 2 };", ""},
+		{"double(x)", "10"},
+		{"let x = x + 1;", ""},
+		{"double(x)", "12"},
+		{"undefined", "ERROR: identifier not found: undefined"},
+		{"x", "6"},
+	}
+
+	for _, engine := range engines {
+		s := newSession(engine, object.DefaultIO())
+		for _, tt := range inputs {
+			program := parseProgram(t, tt.input)
+
+			result := s.run(program)
+			actual := ""
+			if result != nil {
+				actual = result.Inspect()
+			}
+			if actual != tt.expected {
+				t.Errorf("[%s] %q: wrong result. want=%q, got=%q",
+					engine, tt.input, tt.expected, actual)
+			}
+		}
+	}
+}
+
+func TestConformanceOutput(t *testing.T) {
+	input := `puts("a", 1);
+let f = fn(x) { puts(x * 2); x };
+map([1, 2], f);
+puts([1, "b"]);
+if (false) { len = 3; }
+puts(fn() {}(), if (true) {});
+puts("end");`
+
+	expected := "a\n1\n2\n4\n[1,b]\nnull\nnull\nend\n"
+
+	for _, engine := range engines {
+		var out bytes.Buffer
+		s := newSession(engine, &object.IO{Stdout: &out, Stderr: &out})
+		result := s.run(parseProgram(t, input))
+		if err, ok := result.(*object.Error); ok {
+			t.Fatalf("[%s] program failed: %s", engine, err.Inspect())
+		}
+		if out.String() != expected {
+			t.Errorf("[%s] wrong output. want=%q, got=%q", engine, expected, out.String())
+		}
+	}
+}
+
+func runProgram(t *testing.T, engine Engine, input string) object.Object {
+	t.Helper()
+	return newSession(engine, object.DefaultIO()).run(parseProgram(t, input))
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.NewWithFilename("test.mky", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parser errors: %q", input, p.Errors())
+	}
+	return program
+}
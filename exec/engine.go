@@ -0,0 +1,106 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/x-color/monkey/ast"
+	"github.com/x-color/monkey/compiler"
+	"github.com/x-color/monkey/evaluator"
+	"github.com/x-color/monkey/object"
+	"github.com/x-color/monkey/vm"
+)
+
+// Engine is backend executing monkey programs
+type Engine string
+
+// Engines
+const (
+	Evaluator Engine = "eval" // Tree-walking evaluator
+	VM        Engine = "vm"   // Bytecode compiler and virtual machine
+)
+
+// ParseEngine returns engine named name
+func ParseEngine(name string) (Engine, error) {
+	switch e := Engine(name); e {
+	case Evaluator, VM:
+		return e, nil
+	default:
+		return "", fmt.Errorf("unknown engine %q (want %q or %q)", name, Evaluator, VM)
+	}
+}
+
+// session keeps global state of programs executed one after another
+type session interface {
+	// run executes program and returns its value (nil if no value) or *object.Error
+	run(program *ast.Program) object.Object
+}
+
+// newSession returns session whose programs use io for input and output
+func newSession(engine Engine, io *object.IO) session {
+	if engine == VM {
+		return newVMSession(io)
+	}
+	return &evalSession{env: object.NewEnvironment(), io: io}
+}
+
+type evalSession struct {
+	env *object.Environment
+	io  *object.IO
+}
+
+func (s *evalSession) run(program *ast.Program) object.Object {
+	e := evaluator.New(context.Background(), evaluator.Limits{})
+	e.SetIO(s.io)
+	return e.Eval(program, s.env)
+}
+
+type vmSession struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	io          *object.IO
+}
+
+func newVMSession(io *object.IO) *vmSession {
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	return &vmSession{
+		symbolTable: symbolTable,
+		constants:   []object.Object{},
+		globals:     make([]object.Object, vm.GlobalsSize),
+		io:          io,
+	}
+}
+
+func (s *vmSession) run(program *ast.Program) object.Object {
+	comp := compiler.NewWithState(s.symbolTable, s.constants)
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.Error(), Pos: program.Pos(), End: program.End()}
+	}
+
+	bytecode := comp.Bytecode()
+	s.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, s.globals)
+	machine.SetIO(s.io)
+	if err := machine.Run(); err != nil {
+		if e, ok := err.(*object.Error); ok {
+			return e
+		}
+		return &object.Error{Message: err.Error()}
+	}
+
+	if len(program.Statements) == 0 {
+		return nil
+	}
+	// Statements other than expression statement (e.g. 'let') have no value
+	switch program.Statements[len(program.Statements)-1].(type) {
+	case *ast.ExpressionStatement, *ast.ReturnStatement:
+		return machine.LastPoppedStackElem()
+	default:
+		return nil
+	}
+}
@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 
 	"strings"
 
-	"github.com/x-color/monkey/evaluator"
+	"github.com/x-color/monkey/diagnostic"
 	"github.com/x-color/monkey/lexer"
 	"github.com/x-color/monkey/object"
 	"github.com/x-color/monkey/parser"
@@ -19,63 +20,82 @@ const (
 	promptInBlock = ".. "
 )
 
-// Repl starts monkey programing language prompt
-func Repl(in io.Reader, out io.Writer) {
+// Repl starts monkey programing language prompt.
+// Prompts, results and outputs of programs are written to out.
+func Repl(in io.Reader, out io.Writer, engine Engine) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	s := newSession(engine, &object.IO{Stdin: in, Stdout: out, Stderr: out})
+	sources := map[string]string{} // Inputs referred by positions in runtime errors
 
-	for {
-		fmt.Print(prompt)
+	for n := 1; ; n++ {
+		io.WriteString(out, prompt)
 		if !scanner.Scan() {
 			return
 		}
 		line := scanner.Text()
-		for strings.Count(line, "{")-strings.Count(line, "}") > 0 {
-			fmt.Print(promptInBlock)
+		for strings.Count(line, "{")-strings.Count(line, "}") > 0 || strings.Count(line, "`")%2 == 1 {
+			// Block or raw string continues to next line
+			io.WriteString(out, promptInBlock)
 			if !scanner.Scan() {
 				return
 			}
-			line += scanner.Text()
+			line += "\n" + scanner.Text()
 		}
-		l := lexer.New(line)
+		name := fmt.Sprintf("<stdin:%d>", n)
+		sources[name] = line
+		l := lexer.NewWithFilename(name, line)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParseErrors(out, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			printParseErrors(out, p.Diagnostics(), line)
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
+		evaluated := s.run(program)
+		if err, ok := evaluated.(*object.Error); ok {
+			printRuntimeError(out, err, sources)
+		} else if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
 		}
 	}
 }
 
-// ExecFile executes monkey programing language source file
-func ExecFile(fileName string, out io.Writer) {
+// ExecFile executes monkey programing language source file.
+// Outputs and errors of program are written to out.
+func ExecFile(fileName string, out io.Writer, engine Engine) {
 	bytes, err := ioutil.ReadFile(fileName)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(out, err)
 		return
 	}
 	code := string(bytes)
 
-	l := lexer.New(code)
+	l := lexer.NewWithFilename(fileName, code)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	if len(p.Errors()) != 0 {
-		printParseErrors(out, p.Errors())
+	if len(p.Diagnostics()) != 0 {
+		printParseErrors(out, p.Diagnostics(), code)
+		return
 	}
 
-	evaluator.Eval(program, object.NewEnvironment())
+	evaluated := newSession(engine, &object.IO{Stdin: os.Stdin, Stdout: out, Stderr: out}).run(program)
+	if err, ok := evaluated.(*object.Error); ok {
+		printRuntimeError(out, err, map[string]string{fileName: code})
+	}
 }
 
-func printParseErrors(out io.Writer, errors []string) {
+func printParseErrors(out io.Writer, diagnostics []*diagnostic.Diagnostic, src string) {
 	io.WriteString(out, "parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range diagnostics {
+		io.WriteString(out, d.Render(src))
+	}
+}
+
+func printRuntimeError(out io.Writer, err *object.Error, sources map[string]string) {
+	io.WriteString(out, err.StackTrace())
+	if src, ok := sources[err.Pos.Filename]; ok {
+		io.WriteString(out, diagnostic.Snippet(src, err.Pos, err.End))
 	}
 }
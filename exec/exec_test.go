@@ -0,0 +1,28 @@
+package exec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRepl(t *testing.T) {
+	input := `let x = 2;
+puts(x * 3);
+if (x > 1) {
+x
+}
+`
+	expected := ">> " +
+		">> 6\nnull\n" +
+		">> .. .. 2\n" +
+		">> "
+
+	for _, engine := range engines {
+		var out bytes.Buffer
+		Repl(strings.NewReader(input), &out, engine)
+		if out.String() != expected {
+			t.Errorf("[%s] wrong output. want=%q, got=%q", engine, expected, out.String())
+		}
+	}
+}
@@ -1,39 +1,108 @@
 package lexer
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/x-color/monkey/diagnostic"
 	"github.com/x-color/monkey/token"
 )
 
 // Lexer has lexical analyzing info
 type Lexer struct {
 	input        string
-	position     int  // Analyzing charactor position
-	readPosition int  // Next charactor position
-	ch           byte // Analyzing charactor
+	filename     string // Source file name used in token positions
+	position     int    // Byte offset of analyzing charactor
+	readPosition int    // Byte offset of next charactor
+	ch           rune   // Analyzing charactor (decoded from UTF-8)
+	line         int    // Line of analyzing charactor
+	column       int    // Column of analyzing charactor (counted in charactors)
+	keepComments bool   // Whether comments are returned as tokens
+	errors       []*diagnostic.Diagnostic
 }
 
 // New makes new lexical analyzer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+// NewWithFilename makes new lexical analyzer for source file
+func NewWithFilename(filename, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar() // Initialize lexer
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		return // Already reached EOF
+	}
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // EOF
 	} else {
-		l.ch = l.input[l.readPosition]
+		// Invalid UTF-8 byte is read as utf8.RuneError
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += width
+	l.column++
+}
+
+// KeepComments makes lexer return comments as Comment tokens instead of skipping them
+func (l *Lexer) KeepComments() {
+	l.keepComments = true
+}
+
+// Errors returns errors found in analyzing (e.g. unterminated string)
+func (l *Lexer) Errors() []*diagnostic.Diagnostic {
+	return l.errors
+}
+
+func (l *Lexer) addError(code diagnostic.Code, msg string, pos, end token.Position) {
+	l.errors = append(l.errors, &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Message:  msg,
+		Pos:      pos,
+		End:      end,
+	})
+}
+
+// pos returns position of analyzing charactor
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
 // NextToken analyzes next token
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	for {
+		l.skipWhitespace()
+
+		pos := l.pos()
+		tok := l.readToken()
+		tok.Pos = pos
+		tok.End = l.pos()
+		if tok.Type != token.Comment || l.keepComments {
+			return tok
+		}
+	}
+}
 
-	l.skipWhitespace()
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -46,9 +115,19 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.Assign, l.ch)
 		}
 	case '+':
-		tok = newToken(token.Plus, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.PlusAssign, Literal: "+="}
+		} else {
+			tok = newToken(token.Plus, l.ch)
+		}
 	case '-':
-		tok = newToken(token.Minus, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.MinusAssign, Literal: "-="}
+		} else {
+			tok = newToken(token.Minus, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -58,14 +137,80 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.Bang, l.ch)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			l.readChar()
+			tok = token.Token{Type: token.And, Literal: "&&"}
+		} else {
+			tok = newToken(token.BitAnd, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar()
+			tok = token.Token{Type: token.Or, Literal: "||"}
+		} else {
+			tok = newToken(token.BitOr, l.ch)
+		}
+	case '^':
+		tok = newToken(token.BitXor, l.ch)
+	case '~':
+		tok = newToken(token.BitNot, l.ch)
 	case '*':
-		tok = newToken(token.Asterisk, l.ch)
+		switch l.peekChar() {
+		case '*':
+			l.readChar()
+			tok = token.Token{Type: token.Power, Literal: "**"}
+		case '=':
+			l.readChar()
+			tok = token.Token{Type: token.AsteriskAssign, Literal: "*="}
+		default:
+			tok = newToken(token.Asterisk, l.ch)
+		}
+	case '%':
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.PercentAssign, Literal: "%="}
+		} else {
+			tok = newToken(token.Percent, l.ch)
+		}
 	case '/':
-		tok = newToken(token.Slash, l.ch)
+		switch l.peekChar() {
+		case '/':
+			tok.Type = token.Comment
+			tok.Literal = l.readLineComment()
+			return tok
+		case '*':
+			tok.Type = token.Comment
+			tok.Literal = l.readBlockComment()
+			return tok
+		case '=':
+			l.readChar()
+			tok = token.Token{Type: token.SlashAssign, Literal: "/="}
+		default:
+			tok = newToken(token.Slash, l.ch)
+		}
 	case '<':
-		tok = newToken(token.Lt, l.ch)
+		switch l.peekChar() {
+		case '=':
+			l.readChar()
+			tok = token.Token{Type: token.LtEq, Literal: "<="}
+		case '<':
+			l.readChar()
+			tok = token.Token{Type: token.LShift, Literal: "<<"}
+		default:
+			tok = newToken(token.Lt, l.ch)
+		}
 	case '>':
-		tok = newToken(token.Gt, l.ch)
+		switch l.peekChar() {
+		case '=':
+			l.readChar()
+			tok = token.Token{Type: token.GtEq, Literal: ">="}
+		case '>':
+			l.readChar()
+			tok = token.Token{Type: token.RShift, Literal: ">>"}
+		default:
+			tok = newToken(token.Gt, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LParen, l.ch)
 	case ')':
@@ -87,6 +232,9 @@ func (l *Lexer) NextToken() token.Token {
 	case '"':
 		tok.Type = token.String
 		tok.Literal = l.readString()
+	case '`':
+		tok.Type = token.String
+		tok.Literal = l.readRawString()
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.Eof
@@ -96,11 +244,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.Int
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
-			tok = newToken(token.Illegal, l.ch)
+			tok.Type = token.Illegal
+			tok.Literal = l.input[l.position:l.readPosition]
 		}
 	}
 
@@ -116,23 +264,181 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads integer (e.g. '10') or floating-point number (e.g. '3.14', '1e-9')
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.Int)
+	l.readDigits()
+
+	// Fraction needs digits after '.' (e.g. '1.' is not number)
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.Float
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		n := 1 // Length of 'e' and sign
+		if c := l.peekChar(); c == '+' || c == '-' {
+			n = 2
+		}
+		if l.position+n < len(l.input) && isDigit(rune(l.input[l.position+n])) {
+			tokenType = token.Float
+			for i := 0; i < n; i++ {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
+}
+
+// readLineComment reads comment until end of line ('// ...')
+func (l *Lexer) readLineComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
 	return l.input[position:l.position]
 }
 
+// readBlockComment reads comment until '*/' ('/* ... */')
+func (l *Lexer) readBlockComment() string {
+	pos := l.pos()
+	position := l.position
+	l.readChar() // '/'
+	l.readChar() // '*'
+	for {
+		if l.ch == 0 {
+			l.addError(diagnostic.UnterminatedComment, "comment not terminated", pos, l.pos())
+			return l.input[position:l.position]
+		}
+		if l.ch == '*' && l.peekChar() == '/' {
+			l.readChar()
+			l.readChar()
+			return l.input[position:l.position]
+		}
+		l.readChar()
+	}
+}
+
+// readString reads string literal between '"' and returns its value with escape sequences replaced.
+// Last '"' is not read.
 func (l *Lexer) readString() string {
+	pos := l.pos()
+	var out strings.Builder
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			return out.String()
+		case '\\':
+			l.readEscape(&out)
+			if l.ch != 0 {
+				continue
+			}
+			fallthrough
+		case 0:
+			l.addError(diagnostic.UnterminatedString, "string literal not terminated", pos, l.pos())
+			return out.String()
+		default:
+			out.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
+}
+
+// readEscape reads escape sequence ('\n', '\t', '\r', '\\', '\"' or '\u{XXXX}')
+// and writes charactor represented by it.
+// Invalid escape sequence is written as it is.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	pos := l.pos()
+	position := l.position
+	l.readChar()
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '\\', '"':
+		out.WriteRune(l.ch)
+	case 'u':
+		if r, ok := l.readUnicodeEscape(); ok {
+			out.WriteRune(r)
+			return
+		}
+		out.WriteString(l.input[position:l.readPosition])
+		l.addError(diagnostic.InvalidEscape,
+			fmt.Sprintf("invalid unicode escape sequence %s", l.input[position:l.readPosition]),
+			pos, l.endOfChar())
+	case 0:
+		// Reported as unterminated string by caller
+	default:
+		out.WriteString(l.input[position:l.readPosition])
+		l.addError(diagnostic.InvalidEscape,
+			fmt.Sprintf("unknown escape sequence %s", l.input[position:l.readPosition]),
+			pos, l.endOfChar())
+	}
+}
+
+// readUnicodeEscape reads '{XXXX}' of '\u{XXXX}' (1 to 6 hex digits).
+// Analyzing charactor is last charactor read.
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.peekChar() != '{' {
+		return 0, false
+	}
+	l.readChar()
+	position := l.position + 1
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[position:l.readPosition]
+	if l.peekChar() != '}' {
+		return 0, false
+	}
+	l.readChar()
+
+	if len(digits) == 0 || len(digits) > 6 {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(value)) {
+		return 0, false
+	}
+	return rune(value), true
+}
+
+// readRawString reads string literal between '`'. It can contain newlines and has no escape sequence.
+// Last '`' is not read.
+func (l *Lexer) readRawString() string {
+	pos := l.pos()
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case '`':
+			return l.input[position:l.position]
+		case 0:
+			l.addError(diagnostic.UnterminatedString, "raw string literal not terminated", pos, l.pos())
+			return l.input[position:l.position]
 		}
 	}
-	return l.input[position:l.position]
+}
+
+// endOfChar returns position just after analyzing charactor
+func (l *Lexer) endOfChar() token.Position {
+	pos := l.pos()
+	pos.Offset++
+	pos.Column++
+	return pos
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -141,22 +447,29 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
 	}
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// isLetter checks if ch can be used in identifier (Unicode letter or '_')
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
@@ -15,7 +15,7 @@ func TestNextToken(t *testing.T) {
 	};
 	
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -121,3 +121,297 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestNextTokenPosition(t *testing.T) {
+	input := "let x = 5;\nlet name = \"monkey\";\n  x == 10"
+
+	tests := []struct {
+		expectedType      token.TokenType
+		expectedPos       token.Position
+		expectedEndColumn int
+	}{
+		{token.Let, token.Position{Filename: "test.mky", Offset: 0, Line: 1, Column: 1}, 4},
+		{token.Ident, token.Position{Filename: "test.mky", Offset: 4, Line: 1, Column: 5}, 6},
+		{token.Assign, token.Position{Filename: "test.mky", Offset: 6, Line: 1, Column: 7}, 8},
+		{token.Int, token.Position{Filename: "test.mky", Offset: 8, Line: 1, Column: 9}, 10},
+		{token.Semicolon, token.Position{Filename: "test.mky", Offset: 9, Line: 1, Column: 10}, 11},
+		{token.Let, token.Position{Filename: "test.mky", Offset: 11, Line: 2, Column: 1}, 4},
+		{token.Ident, token.Position{Filename: "test.mky", Offset: 15, Line: 2, Column: 5}, 9},
+		{token.Assign, token.Position{Filename: "test.mky", Offset: 20, Line: 2, Column: 10}, 11},
+		{token.String, token.Position{Filename: "test.mky", Offset: 22, Line: 2, Column: 12}, 20},
+		{token.Semicolon, token.Position{Filename: "test.mky", Offset: 30, Line: 2, Column: 20}, 21},
+		{token.Ident, token.Position{Filename: "test.mky", Offset: 34, Line: 3, Column: 3}, 4},
+		{token.Eq, token.Position{Filename: "test.mky", Offset: 36, Line: 3, Column: 5}, 7},
+		{token.Int, token.Position{Filename: "test.mky", Offset: 39, Line: 3, Column: 8}, 10},
+		{token.Eof, token.Position{Filename: "test.mky", Offset: 41, Line: 3, Column: 10}, 10},
+	}
+
+	l := NewWithFilename("test.mky", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - position wrong. expected=%+v, got=%+v",
+				i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End.Column != tt.expectedEndColumn {
+			t.Fatalf("tests[%d] - end column wrong. expected=%d, got=%d",
+				i, tt.expectedEndColumn, tok.End.Column)
+		}
+	}
+}
+
+func TestNextTokenNumber(t *testing.T) {
+	input := "5 3.14 1e-9 2E+3 1.5e2 7. 1e x1.5"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.Int, "5"},
+		{token.Float, "3.14"},
+		{token.Float, "1e-9"},
+		{token.Float, "2E+3"},
+		{token.Float, "1.5e2"},
+		{token.Int, "7"},
+		{token.Illegal, "."},
+		{token.Int, "1"},
+		{token.Ident, "e"},
+		{token.Ident, "x"},
+		{token.Float, "1.5"},
+		{token.Eof, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenOperator(t *testing.T) {
+	input := "a % b ** c * d <= e >= f < g > h & i | j ^ ~k << l >> m += -= *= /= %="
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.Ident, "a"},
+		{token.Percent, "%"},
+		{token.Ident, "b"},
+		{token.Power, "**"},
+		{token.Ident, "c"},
+		{token.Asterisk, "*"},
+		{token.Ident, "d"},
+		{token.LtEq, "<="},
+		{token.Ident, "e"},
+		{token.GtEq, ">="},
+		{token.Ident, "f"},
+		{token.Lt, "<"},
+		{token.Ident, "g"},
+		{token.Gt, ">"},
+		{token.Ident, "h"},
+		{token.BitAnd, "&"},
+		{token.Ident, "i"},
+		{token.BitOr, "|"},
+		{token.Ident, "j"},
+		{token.BitXor, "^"},
+		{token.BitNot, "~"},
+		{token.Ident, "k"},
+		{token.LShift, "<<"},
+		{token.Ident, "l"},
+		{token.RShift, ">>"},
+		{token.Ident, "m"},
+		{token.PlusAssign, "+="},
+		{token.MinusAssign, "-="},
+		{token.AsteriskAssign, "*="},
+		{token.SlashAssign, "/="},
+		{token.PercentAssign, "%="},
+		{token.Eof, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenComment(t *testing.T) {
+	input := `// line comment
+let x = 1; // trailing
+/* block
+   comment */ x / 2 /* unterminated`
+
+	tests := []struct {
+		keep     bool
+		expected []token.Token
+	}{
+		{
+			false,
+			[]token.Token{
+				{Type: token.Let, Literal: "let"},
+				{Type: token.Ident, Literal: "x"},
+				{Type: token.Assign, Literal: "="},
+				{Type: token.Int, Literal: "1"},
+				{Type: token.Semicolon, Literal: ";"},
+				{Type: token.Ident, Literal: "x"},
+				{Type: token.Slash, Literal: "/"},
+				{Type: token.Int, Literal: "2"},
+				{Type: token.Eof, Literal: ""},
+			},
+		},
+		{
+			true,
+			[]token.Token{
+				{Type: token.Comment, Literal: "// line comment"},
+				{Type: token.Let, Literal: "let"},
+				{Type: token.Ident, Literal: "x"},
+				{Type: token.Assign, Literal: "="},
+				{Type: token.Int, Literal: "1"},
+				{Type: token.Semicolon, Literal: ";"},
+				{Type: token.Comment, Literal: "// trailing"},
+				{Type: token.Comment, Literal: "/* block\n   comment */"},
+				{Type: token.Ident, Literal: "x"},
+				{Type: token.Slash, Literal: "/"},
+				{Type: token.Int, Literal: "2"},
+				{Type: token.Comment, Literal: "/* unterminated"},
+				{Type: token.Eof, Literal: ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := New(input)
+		if tt.keep {
+			l.KeepComments()
+		}
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("keep=%t tests[%d] - token wrong. expected=%q %q, got=%q %q",
+					tt.keep, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+		if len(l.Errors()) != 1 || l.Errors()[0].Message != "comment not terminated" {
+			t.Errorf("keep=%t - unterminated comment not reported. got=%v", tt.keep, l.Errors())
+		}
+	}
+
+	l := New("/* a\nb */ x")
+	l.KeepComments()
+	comment := l.NextToken()
+	if comment.Pos.Line != 1 || comment.End.Line != 2 || comment.End.Column != 5 {
+		t.Errorf("comment position wrong. got=%s-%s", comment.Pos, comment.End)
+	}
+}
+
+func TestNextTokenString(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedLiteral  string
+		expectedErrors   []string
+		expectedEndLine  int
+		expectedNextType token.TokenType
+	}{
+		{`"foo bar"`, "foo bar", nil, 1, token.Eof},
+		{`"a\nb\tc\r"`, "a\nb\tc\r", nil, 1, token.Eof},
+		{`"say \"hi\" \\o/"`, `say "hi" \o/`, nil, 1, token.Eof},
+		{`"\u{65E5}\u{672c}\u{1F600}"`, "日本😀", nil, 1, token.Eof},
+		{`"a\qb"`, `a\qb`, []string{"1:3: unknown escape sequence \\q"}, 1, token.Eof},
+		{`"\u{110000}"`, `\u{110000}`, []string{"1:2: invalid unicode escape sequence \\u{110000}"}, 1, token.Eof},
+		{`"\u{}"`, `\u{}`, []string{"1:2: invalid unicode escape sequence \\u{}"}, 1, token.Eof},
+		{`"abc`, "abc", []string{"1:1: string literal not terminated"}, 1, token.Eof},
+		{`"abc\`, "abc", []string{"1:1: string literal not terminated"}, 1, token.Eof},
+		{"`raw \\n \"`;", `raw \n "`, nil, 1, token.Semicolon},
+		{"`line1\nline2`", "line1\nline2", nil, 2, token.Eof},
+		{"`abc", "abc", []string{"1:1: raw string literal not terminated"}, 1, token.Eof},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.String {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, token.String, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.End.Line != tt.expectedEndLine {
+			t.Errorf("tests[%d] - end line wrong. expected=%d, got=%d", i, tt.expectedEndLine, tok.End.Line)
+		}
+		if next := l.NextToken(); next.Type != tt.expectedNextType {
+			t.Errorf("tests[%d] - next tokentype wrong. expected=%q, got=%q", i, tt.expectedNextType, next.Type)
+		}
+
+		errors := []string{}
+		for _, e := range l.Errors() {
+			errors = append(errors, e.Error())
+		}
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("tests[%d] - wrong errors. expected=%q, got=%q", i, tt.expectedErrors, errors)
+			continue
+		}
+		for j, e := range tt.expectedErrors {
+			if errors[j] != e {
+				t.Errorf("tests[%d] - wrong error. expected=%q, got=%q", i, e, errors[j])
+			}
+		}
+	}
+}
+
+func TestNextTokenUnicode(t *testing.T) {
+	input := "let 名前 = \"日本😀\";\n_über + x\xff"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+		expectedOffset  int
+	}{
+		{token.Let, "let", 1, 0},
+		{token.Ident, "名前", 5, 4},
+		{token.Assign, "=", 8, 11},
+		{token.String, "日本😀", 10, 13},
+		{token.Semicolon, ";", 15, 25},
+		{token.Ident, "_über", 1, 27},
+		{token.Plus, "+", 7, 34},
+		{token.Ident, "x", 9, 36},
+		{token.Illegal, "\xff", 10, 37},
+		{token.Eof, "", 11, 38},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Column != tt.expectedColumn || tok.Pos.Offset != tt.expectedOffset {
+			t.Fatalf("tests[%d] - position wrong. expected column=%d offset=%d, got column=%d offset=%d",
+				i, tt.expectedColumn, tt.expectedOffset, tok.Pos.Column, tok.Pos.Offset)
+		}
+	}
+}
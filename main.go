@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
@@ -9,7 +10,17 @@ import (
 )
 
 func main() {
-	if len(os.Args) == 1 {
+	engineName := flag.String("engine", string(exec.Evaluator),
+		"execution engine ('eval' or 'vm')")
+	flag.Parse()
+
+	engine, err := exec.ParseEngine(*engineName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if flag.NArg() == 0 {
 		user, err := user.Current()
 		if err != nil {
 			panic(err)
@@ -17,9 +28,9 @@ func main() {
 		fmt.Printf("Hello %s! This is the Monkey programing language!\n",
 			user.Username)
 		fmt.Println("Feel free to type in commands")
-		exec.Repl(os.Stdin, os.Stdout)
+		exec.Repl(os.Stdin, os.Stdout, engine)
 	} else {
-		fileName := os.Args[1]
-		exec.ExecFile(fileName, os.Stdout)
+		fileName := flag.Arg(0)
+		exec.ExecFile(fileName, os.Stdout, engine)
 	}
 }
@@ -0,0 +1,144 @@
+// Package monkey provides API to embed monkey programing language in Go programs.
+package monkey
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/x-color/monkey/diagnostic"
+	"github.com/x-color/monkey/evaluator"
+	"github.com/x-color/monkey/lexer"
+	"github.com/x-color/monkey/object"
+	"github.com/x-color/monkey/parser"
+)
+
+// Interpreter executes monkey programs keeping their global variables.
+// Each interpreter has its own builtin functions,
+// so several interpreters can be used at the same time.
+type Interpreter struct {
+	builtins map[string]*object.Builtin // Builtin functions registered by host
+	globals  *object.Environment        // Global variables of programs
+	limits   evaluator.Limits           // Limits applied to each execution
+	io       *object.IO                 // Standard input and outputs used by builtin functions
+}
+
+// ParseError is error of parsing monkey program
+type ParseError struct {
+	Diagnostics []*diagnostic.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	msgs := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// New returns new interpreter
+func New() *Interpreter {
+	return &Interpreter{
+		builtins: make(map[string]*object.Builtin),
+		globals:  object.NewEnvironment(),
+		io:       object.DefaultIO(),
+	}
+}
+
+// SetLimits sets limits applied to each call of Eval, EvalFile and Call.
+// Execution exceeding limits fails with *object.Error
+// whose Cause is evaluator.ErrStepLimitExceeded, evaluator.ErrCallDepthExceeded
+// or evaluator.ErrAllocLimitExceeded.
+func (i *Interpreter) SetLimits(limits evaluator.Limits) {
+	i.limits = limits
+}
+
+// SetIO sets standard input and outputs used by builtin functions (e.g. 'puts').
+// Process IO is used by default.
+func (i *Interpreter) SetIO(io *object.IO) {
+	i.io = io
+}
+
+// Eval executes src and returns value of it.
+// Error is *ParseError or *object.Error.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.EvalContext(context.Background(), src)
+}
+
+// EvalContext is Eval stopping execution when ctx is done.
+// Then error is *object.Error whose Cause is ctx.Err().
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	return i.eval(ctx, "", src)
+}
+
+// EvalFile executes source file and returns value of it.
+// Error is *ParseError, *object.Error or error of reading file.
+func (i *Interpreter) EvalFile(path string) (object.Object, error) {
+	return i.EvalFileContext(context.Background(), path)
+}
+
+// EvalFileContext is EvalFile stopping execution when ctx is done
+func (i *Interpreter) EvalFileContext(ctx context.Context, path string) (object.Object, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return i.eval(ctx, path, string(bytes))
+}
+
+func (i *Interpreter) eval(ctx context.Context, filename, src string) (object.Object, error) {
+	l := lexer.NewWithFilename(filename, src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return nil, &ParseError{Diagnostics: p.Diagnostics()}
+	}
+
+	return result(i.evaluator(ctx).Eval(program, i.globals))
+}
+
+// Set stores val as global variable named name
+func (i *Interpreter) Set(name string, val object.Object) {
+	i.globals.Set(name, val)
+}
+
+// Get returns global variable named name
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.globals.Get(name)
+}
+
+// RegisterBuiltin adds builtin function named name to the interpreter.
+// It overrides default builtin function with the same name.
+// Programs cannot assign to it like default builtin functions.
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.builtins[name] = &object.Builtin{Fn: fn}
+}
+
+// Call calls function object fn (e.g. closure got by Get) with args
+func (i *Interpreter) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	return i.CallContext(context.Background(), fn, args...)
+}
+
+// CallContext is Call stopping execution when ctx is done
+func (i *Interpreter) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	return result(i.evaluator(ctx).Apply(fn, args...))
+}
+
+// evaluator returns evaluator for an execution under ctx
+func (i *Interpreter) evaluator(ctx context.Context) *evaluator.Evaluator {
+	e := evaluator.New(ctx, i.limits)
+	e.SetIO(i.io)
+	e.SetBuiltins(i.builtins)
+	return e
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, err
+	}
+	if obj == nil {
+		// Statements like 'let' have no value
+		return object.NullValue, nil
+	}
+	return obj, nil
+}
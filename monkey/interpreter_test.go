@@ -0,0 +1,257 @@
+package monkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/x-color/monkey/evaluator"
+	"github.com/x-color/monkey/object"
+)
+
+func TestInterpreterEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2", "3"},
+		{"let x = 1;", "null"},
+		{`let add = fn(a, b) { a + b }; add(3, 4)`, "7"},
+		{`len("monkey")`, "6"},
+	}
+
+	for _, tt := range tests {
+		interp := New()
+		result, err := interp.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", tt.input, err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%q: wrong result. want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	interp := New()
+
+	if _, err := interp.Eval("let = 1;"); err == nil {
+		t.Errorf("parse error not returned")
+	} else if _, ok := err.(*ParseError); !ok {
+		t.Errorf("error is not *ParseError. got=%T", err)
+	}
+
+	_, err := interp.Eval("foo")
+	e, ok := err.(*object.Error)
+	if !ok {
+		t.Fatalf("error is not *object.Error. got=%T (%+v)", err, err)
+	}
+	if e.Message != "identifier not found: foo" {
+		t.Errorf("wrong error message. got=%q", e.Message)
+	}
+}
+
+func TestInterpreterGlobals(t *testing.T) {
+	interp := New()
+	interp.Set("limit", &object.Integer{Value: 10})
+
+	if _, err := interp.Eval("let doubled = limit * 2;"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	doubled, ok := interp.Get("doubled")
+	if !ok {
+		t.Fatalf("global variable 'doubled' not found")
+	}
+	if doubled.Inspect() != "20" {
+		t.Errorf("wrong value. want=20, got=%s", doubled.Inspect())
+	}
+
+	if _, ok := interp.Get("undefined"); ok {
+		t.Errorf("undefined variable found")
+	}
+}
+
+func TestInterpreterHashFromHost(t *testing.T) {
+	b := &object.String{Value: "b"}
+	a := &object.String{Value: "a"}
+	interp := New()
+	interp.Set("h", &object.Hash{Pairs: map[object.HashKey]object.HashPair{
+		b.HashKey(): {Key: b, Value: &object.Integer{Value: 2}},
+		a.HashKey(): {Key: a, Value: &object.Integer{Value: 1}},
+	}})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"len(h)", "2"},
+		{"keys(h)", "[a,b]"},
+		{"h", "{a: 1, b: 2}"},
+		{`let s = ""; for (k, v in h) { s = s + k }; s`, "ab"},
+		{`h["c"] = 3; delete(h, "a")`, "{b: 2, c: 3}"},
+	}
+
+	for _, tt := range tests {
+		obj, err := interp.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("Eval(%q) returned error: %s", tt.input, err)
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("Eval(%q) wrong. want=%s, got=%s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func TestInterpreterSetIO(t *testing.T) {
+	var out bytes.Buffer
+	interp := New()
+	interp.SetIO(&object.IO{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out})
+
+	if _, err := interp.Eval(`puts("hello")`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fn, err := interp.Eval(`fn(x) { puts(x) }`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := interp.Call(fn, &object.String{Value: "world"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if out.String() != "hello\nworld\n" {
+		t.Errorf("wrong output. want=%q, got=%q", "hello\nworld\n", out.String())
+	}
+}
+
+func TestInterpreterRegisterBuiltin(t *testing.T) {
+	a := New()
+	b := New()
+
+	a.RegisterBuiltin("answer", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 42}
+	})
+	a.RegisterBuiltin("len", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: -1}
+	})
+
+	result, err := a.Eval(`answer() + len("abc")`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "41" {
+		t.Errorf("wrong result. want=41, got=%s", result.Inspect())
+	}
+
+	if _, err := b.Eval("answer()"); err == nil {
+		t.Errorf("builtin registered to other interpreter is visible")
+	}
+	result, err = b.Eval(`len("abc")`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "3" {
+		t.Errorf("default builtin is overridden. got=%s", result.Inspect())
+	}
+
+	_, err = a.Eval("answer = 1")
+	if err == nil || err.Error() != "cannot assign to builtin function: answer" {
+		t.Errorf("wrong error of assigning to registered builtin. got=%v", err)
+	}
+	result, err = a.Eval("answer()")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("registered builtin is replaced. got=%s", result.Inspect())
+	}
+}
+
+func TestInterpreterCall(t *testing.T) {
+	interp := New()
+	if _, err := interp.Eval("let counter = 0; let add = fn(x) { let counter = counter + x; counter };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	add, _ := interp.Get("add")
+	result, err := interp.Call(add, &object.Integer{Value: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "5" {
+		t.Errorf("wrong result. want=5, got=%s", result.Inspect())
+	}
+
+	if _, err := interp.Call(&object.Integer{Value: 1}); err == nil {
+		t.Errorf("calling non-function object did not fail")
+	}
+}
+
+func TestInterpreterEvalFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "script.mky")
+	if err := ioutil.WriteFile(path, []byte("let x = 2;\nx + y"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New().EvalFile(path)
+	e, ok := err.(*object.Error)
+	if !ok {
+		t.Fatalf("error is not *object.Error. got=%T (%+v)", err, err)
+	}
+	if e.Pos.Filename != path || e.Pos.Line != 2 {
+		t.Errorf("wrong error position. got=%s", e.Pos)
+	}
+}
+
+func TestInterpreterLimits(t *testing.T) {
+	interp := New()
+	interp.SetLimits(evaluator.Limits{MaxSteps: 1000})
+
+	_, err := interp.Eval("while (true) { }")
+	if !errors.Is(err, evaluator.ErrStepLimitExceeded) {
+		t.Errorf("wrong error. want=%v, got=%v", evaluator.ErrStepLimitExceeded, err)
+	}
+
+	// Steps are counted for each execution
+	for n := 0; n < 3; n++ {
+		if _, err := interp.Eval("let x = 1 + 2;"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	interp.SetLimits(evaluator.Limits{})
+	_, err = interp.EvalContext(ctx, "while (true) { }")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("wrong error. want=%v, got=%v", context.Canceled, err)
+	}
+}
+
+func TestInterpreterDefaultCallDepth(t *testing.T) {
+	// Unlimited recursion fails with error instead of overflowing Go stack
+	_, err := New().Eval("let f = fn(n) { f(n + 1) }; f(0)")
+	if !errors.Is(err, evaluator.ErrCallDepthExceeded) {
+		t.Errorf("wrong error. want=%v, got=%v", evaluator.ErrCallDepthExceeded, err)
+	}
+
+	// Recursion within default depth succeeds
+	obj, err := New().Eval("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5000)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if obj.Inspect() != "5000" {
+		t.Errorf("wrong result. want=5000, got=%s", obj.Inspect())
+	}
+}
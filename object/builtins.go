@@ -0,0 +1,1419 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Builtins is list of builtin functions shared by evaluator and vm.
+// Index in this list is operand of builtin function in bytecode.
+// Builtin functions treat strings as sequences of charactors (Unicode code points),
+// except bytelen counting bytes of UTF-8.
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"len",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			switch arg := args[0].(type) {
+			case *String:
+				return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *Array:
+				return &Integer{Value: int64(len(arg.Elements))}
+			case *Range:
+				return &Integer{Value: arg.Len()}
+			case *Hash:
+				return &Integer{Value: int64(len(arg.Pairs))}
+			default:
+				return newError("argument to 'len' not supported, got %s",
+					arg.Type())
+			}
+		}},
+	},
+	{
+		"first",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != ArrayObj {
+				return newError("argument to `first` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+
+			return NullValue
+		}},
+	},
+	{
+		"last",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != ArrayObj {
+				return newError("argument to `last` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+
+			return NullValue
+		}},
+	},
+	{
+		"rest",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != ArrayObj {
+				return newError("argument to `rest` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]Object, length-1, length-1)
+				copy(newElements, arr.Elements[1:])
+				return &Array{Elements: newElements}
+			}
+
+			return NullValue
+		}},
+	},
+	{
+		"push",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != ArrayObj {
+				return newError("argument to `push` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			newElements := make([]Object, length+1, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+			return &Array{Elements: newElements}
+		}},
+	},
+	{
+		"puts",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			out := runtimeIO(rt).Stdout
+			for _, arg := range args {
+				fmt.Fprintln(out, arg.Inspect())
+			}
+			return NullValue
+		}},
+	},
+	{
+		"int",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Integer, *BigInt:
+				return arg
+			case *Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				if arg.Value >= math.MinInt64 && arg.Value < math.MaxInt64 {
+					return &Integer{Value: int64(arg.Value)}
+				}
+				value, _ := big.NewFloat(arg.Value).Int(nil)
+				return NewBigInt(value)
+			case *String:
+				value, ok := new(big.Int).SetString(arg.Value, 10)
+				if !ok {
+					return newError("could not parse %q as integer", arg.Value)
+				}
+				return NewBigInt(value)
+			default:
+				return newError("argument to `int` not supported, got %s",
+					arg.Type())
+			}
+		}},
+	},
+	{
+		"float",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Integer:
+				return &Float{Value: float64(arg.Value)}
+			case *BigInt:
+				return &Float{Value: arg.Float()}
+			case *Float:
+				return arg
+			case *String:
+				value, err := strconv.ParseFloat(arg.Value, 64)
+				if err != nil {
+					return newError("could not parse %q as float", arg.Value)
+				}
+				return &Float{Value: value}
+			default:
+				return newError("argument to `float` not supported, got %s",
+					arg.Type())
+			}
+		}},
+	},
+	{
+		"sqrt",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			x, err := numberArg("sqrt", args[0])
+			if err != nil {
+				return err
+			}
+			return &Float{Value: math.Sqrt(x)}
+		}},
+	},
+	{
+		"floor",
+		&Builtin{Fn: func(args ...Object) Object {
+			return roundNumber("floor", math.Floor, args)
+		}},
+	},
+	{
+		"ceil",
+		&Builtin{Fn: func(args ...Object) Object {
+			return roundNumber("ceil", math.Ceil, args)
+		}},
+	},
+	{
+		"pow",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() == IntegerObj && args[1].Type() == IntegerObj {
+				return IntegerInfix("**", args[0], args[1])
+			}
+
+			x, err := numberArg("pow", args[0])
+			if err != nil {
+				return err
+			}
+			y, err := numberArg("pow", args[1])
+			if err != nil {
+				return err
+			}
+			return &Float{Value: math.Pow(x, y)}
+		}},
+	},
+	{
+		"abs",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Integer, *BigInt:
+				if IntegerInfix("<", arg, &Integer{Value: 0}) == TrueValue {
+					// abs of minimum int64 is promoted to big integer
+					return NegateInteger(arg)
+				}
+				return arg
+			case *Float:
+				return &Float{Value: math.Abs(arg.Value)}
+			default:
+				return newError("argument to `abs` must be INTEGER or FLOAT, got %s",
+					arg.Type())
+			}
+		}},
+	},
+	{
+		"bytelen",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != StringObj {
+				return newError("argument to `bytelen` must be STRING, got %s",
+					args[0].Type())
+			}
+			return &Integer{Value: int64(len(args[0].(*String).Value))}
+		}},
+	},
+	{
+		"range",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1..3",
+					len(args))
+			}
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				switch arg := arg.(type) {
+				case *Integer:
+					bounds[i] = arg.Value
+				case *BigInt:
+					return newError("argument to `range` is too large: %s", arg.Inspect())
+				default:
+					return newError("argument to `range` must be INTEGER, got %s",
+						arg.Type())
+				}
+			}
+
+			switch len(bounds) {
+			case 1:
+				return &Range{Start: 0, End: bounds[0], Step: 1}
+			case 2:
+				return &Range{Start: bounds[0], End: bounds[1], Step: 1}
+			default:
+				if bounds[2] == 0 {
+					return newError("step of `range` must not be zero")
+				}
+				return &Range{Start: bounds[0], End: bounds[1], Step: bounds[2]}
+			}
+		}},
+	},
+	{
+		"keys",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			hash, err := hashArg("keys", args[0])
+			if err != nil {
+				return err
+			}
+
+			pairs := hash.OrderedPairs()
+			elements := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Key
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"values",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			hash, err := hashArg("values", args[0])
+			if err != nil {
+				return err
+			}
+
+			pairs := hash.OrderedPairs()
+			elements := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Value
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"items",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			hash, err := hashArg("items", args[0])
+			if err != nil {
+				return err
+			}
+
+			pairs := hash.OrderedPairs()
+			elements := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = &Array{Elements: []Object{pair.Key, pair.Value}}
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"has",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			hash, err := hashArg("has", args[0])
+			if err != nil {
+				return err
+			}
+			key, err := hashKeyArg(args[1])
+			if err != nil {
+				return err
+			}
+
+			if _, ok := hash.Pairs[key]; ok {
+				return TrueValue
+			}
+			return FalseValue
+		}},
+	},
+	{
+		"delete",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			hash, err := hashArg("delete", args[0])
+			if err != nil {
+				return err
+			}
+			key, err := hashKeyArg(args[1])
+			if err != nil {
+				return err
+			}
+
+			newHash := copyHash(hash)
+			newHash.Delete(key)
+			return newHash
+		}},
+	},
+	{
+		"merge",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			hash, err := hashArg("merge", args[0])
+			if err != nil {
+				return err
+			}
+			other, err := hashArg("merge", args[1])
+			if err != nil {
+				return err
+			}
+
+			// Values of second hash take precedence
+			newHash := copyHash(hash)
+			for _, pair := range other.OrderedPairs() {
+				newHash.Set(pair.Key.(Hashable).HashKey(), pair)
+			}
+			return newHash
+		}},
+	},
+	{
+		"get",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=2..3",
+					len(args))
+			}
+			hash, err := hashArg("get", args[0])
+			if err != nil {
+				return err
+			}
+			key, err := hashKeyArg(args[1])
+			if err != nil {
+				return err
+			}
+
+			if pair, ok := hash.Pairs[key]; ok {
+				return pair.Value
+			}
+			if len(args) == 3 {
+				return args[2]
+			}
+			return NullValue
+		}},
+	},
+	{
+		"map",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			arr, err := arrayArg("map", args[0])
+			if err != nil {
+				return err
+			}
+
+			elements := make([]Object, len(arr.Elements))
+			for i, elem := range arr.Elements {
+				result := apply(rt, args[1], elem)
+				if isError(result) {
+					return result
+				}
+				elements[i] = result
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"filter",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			arr, err := arrayArg("filter", args[0])
+			if err != nil {
+				return err
+			}
+
+			elements := []Object{}
+			for _, elem := range arr.Elements {
+				result := apply(rt, args[1], elem)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					elements = append(elements, elem)
+				}
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"reduce",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=2..3",
+					len(args))
+			}
+			arr, err := arrayArg("reduce", args[0])
+			if err != nil {
+				return err
+			}
+
+			// First element is initial value if it is not given
+			elements := arr.Elements
+			var acc Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else {
+				if len(elements) == 0 {
+					return newError("reduce of empty array with no initial value")
+				}
+				acc = elements[0]
+				elements = elements[1:]
+			}
+
+			for _, elem := range elements {
+				acc = apply(rt, args[1], acc, elem)
+				if isError(acc) {
+					return acc
+				}
+			}
+			return acc
+		}},
+	},
+	{
+		"find",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			arr, err := arrayArg("find", args[0])
+			if err != nil {
+				return err
+			}
+
+			for _, elem := range arr.Elements {
+				result := apply(rt, args[1], elem)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return elem
+				}
+			}
+			return NullValue
+		}},
+	},
+	{
+		"any",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			return testElements(rt, "any", true, args)
+		}},
+	},
+	{
+		"all",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			return testElements(rt, "all", false, args)
+		}},
+	},
+	{
+		"sort",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1..2",
+					len(args))
+			}
+			arr, err := arrayArg("sort", args[0])
+			if err != nil {
+				return err
+			}
+
+			elements := make([]Object, len(arr.Elements))
+			copy(elements, arr.Elements)
+
+			// Comparator returns true if its first argument is less than second one,
+			// or negative, zero or positive integer like three-way comparison
+			less := func(a, b Object) Object {
+				return compareObjects(a, b)
+			}
+			if len(args) == 2 {
+				less = func(a, b Object) Object {
+					result := apply(rt, args[1], a, b)
+					switch {
+					case isError(result) || result.Type() == BooleanObj:
+						return result
+					case result.Type() == IntegerObj:
+						return IntegerInfix("<", result, &Integer{Value: 0})
+					default:
+						return newError("comparator of `sort` must return BOOLEAN or INTEGER, got %s",
+							result.Type())
+					}
+				}
+			}
+
+			var sortErr Object
+			sort.SliceStable(elements, func(i, j int) bool {
+				if sortErr != nil {
+					return false
+				}
+				result := less(elements[i], elements[j])
+				if isError(result) {
+					sortErr = result
+					return false
+				}
+				return result == TrueValue
+			})
+			if sortErr != nil {
+				return sortErr
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"reverse",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			arr, err := arrayArg("reverse", args[0])
+			if err != nil {
+				return err
+			}
+
+			length := len(arr.Elements)
+			elements := make([]Object, length)
+			for i, elem := range arr.Elements {
+				elements[length-1-i] = elem
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"concat",
+		&Builtin{Fn: func(args ...Object) Object {
+			elements := []Object{}
+			for _, arg := range args {
+				arr, err := arrayArg("concat", arg)
+				if err != nil {
+					return err
+				}
+				elements = append(elements, arr.Elements...)
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"join",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1..2",
+					len(args))
+			}
+			arr, err := arrayArg("join", args[0])
+			if err != nil {
+				return err
+			}
+			sep := ""
+			if len(args) == 2 {
+				str, ok := args[1].(*String)
+				if !ok {
+					return newError("separator of `join` must be STRING, got %s",
+						args[1].Type())
+				}
+				sep = str.Value
+			}
+
+			strs := make([]string, len(arr.Elements))
+			for i, elem := range arr.Elements {
+				strs[i] = elem.Inspect()
+			}
+			return &String{Value: strings.Join(strs, sep)}
+		}},
+	},
+	{
+		"indexOf",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Array:
+				return &Integer{Value: int64(indexOf(arg.Elements, args[1]))}
+			case *String:
+				substr, err := stringArg("indexOf", args[1])
+				if err != nil {
+					return err
+				}
+				return &Integer{Value: int64(stringIndex(arg.Value, substr))}
+			default:
+				return newError("argument to `indexOf` must be ARRAY or STRING, got %s",
+					arg.Type())
+			}
+		}},
+	},
+	{
+		"contains",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Array:
+				return nativeBool(indexOf(arg.Elements, args[1]) >= 0)
+			case *String:
+				substr, err := stringArg("contains", args[1])
+				if err != nil {
+					return err
+				}
+				return nativeBool(strings.Contains(arg.Value, substr))
+			default:
+				return newError("argument to `contains` must be ARRAY or STRING, got %s",
+					arg.Type())
+			}
+		}},
+	},
+	{
+		"slice",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=2..3",
+					len(args))
+			}
+			arr, err := arrayArg("slice", args[0])
+			if err != nil {
+				return err
+			}
+			start, end, err := sliceBounds("slice", len(arr.Elements), args[1:])
+			if err != nil {
+				return err
+			}
+
+			elements := make([]Object, end-start)
+			copy(elements, arr.Elements[start:end])
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"zip",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			left, err := arrayArg("zip", args[0])
+			if err != nil {
+				return err
+			}
+			right, err := arrayArg("zip", args[1])
+			if err != nil {
+				return err
+			}
+
+			// Extra elements of longer array are ignored
+			length := len(left.Elements)
+			if len(right.Elements) < length {
+				length = len(right.Elements)
+			}
+			elements := make([]Object, length)
+			for i := range elements {
+				elements[i] = &Array{Elements: []Object{left.Elements[i], right.Elements[i]}}
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"flatten",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			arr, err := arrayArg("flatten", args[0])
+			if err != nil {
+				return err
+			}
+
+			// Only one level of nested arrays is flattened
+			elements := []Object{}
+			for _, elem := range arr.Elements {
+				if inner, ok := elem.(*Array); ok {
+					elements = append(elements, inner.Elements...)
+				} else {
+					elements = append(elements, elem)
+				}
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"unique",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			arr, err := arrayArg("unique", args[0])
+			if err != nil {
+				return err
+			}
+
+			// First occurrence of equal elements is kept
+			elements := []Object{}
+			seen := map[HashKey]bool{}
+			for _, elem := range arr.Elements {
+				if key, ok := elem.(Hashable); ok {
+					if seen[key.HashKey()] {
+						continue
+					}
+					seen[key.HashKey()] = true
+				} else if indexOf(elements, elem) >= 0 {
+					continue
+				}
+				elements = append(elements, elem)
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"split",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1..2",
+					len(args))
+			}
+			str, err := stringArg("split", args[0])
+			if err != nil {
+				return err
+			}
+
+			// String is split around white spaces if separator is not given
+			var strs []string
+			if len(args) == 2 {
+				sep, err := stringArg("split", args[1])
+				if err != nil {
+					return err
+				}
+				strs = strings.Split(str, sep)
+			} else {
+				strs = strings.Fields(str)
+			}
+			return stringArray(strs)
+		}},
+	},
+	{
+		"trim",
+		&Builtin{Fn: func(args ...Object) Object {
+			return mapString("trim", strings.TrimSpace, args)
+		}},
+	},
+	{
+		"trimLeft",
+		&Builtin{Fn: func(args ...Object) Object {
+			return mapString("trimLeft", func(s string) string {
+				return strings.TrimLeftFunc(s, unicode.IsSpace)
+			}, args)
+		}},
+	},
+	{
+		"trimRight",
+		&Builtin{Fn: func(args ...Object) Object {
+			return mapString("trimRight", func(s string) string {
+				return strings.TrimRightFunc(s, unicode.IsSpace)
+			}, args)
+		}},
+	},
+	{
+		"upper",
+		&Builtin{Fn: func(args ...Object) Object {
+			return mapString("upper", strings.ToUpper, args)
+		}},
+	},
+	{
+		"lower",
+		&Builtin{Fn: func(args ...Object) Object {
+			return mapString("lower", strings.ToLower, args)
+		}},
+	},
+	{
+		"replace",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3",
+					len(args))
+			}
+			strs := make([]string, len(args))
+			for i, arg := range args {
+				str, err := stringArg("replace", arg)
+				if err != nil {
+					return err
+				}
+				strs[i] = str
+			}
+			// All occurrences are replaced
+			return &String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+		}},
+	},
+	{
+		"startsWith",
+		&Builtin{Fn: func(args ...Object) Object {
+			return testString("startsWith", strings.HasPrefix, args)
+		}},
+	},
+	{
+		"endsWith",
+		&Builtin{Fn: func(args ...Object) Object {
+			return testString("endsWith", strings.HasSuffix, args)
+		}},
+	},
+	{
+		"substring",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=2..3",
+					len(args))
+			}
+			str, err := stringArg("substring", args[0])
+			if err != nil {
+				return err
+			}
+			runes := []rune(str)
+			start, end, err := sliceBounds("substring", len(runes), args[1:])
+			if err != nil {
+				return err
+			}
+			return &String{Value: string(runes[start:end])}
+		}},
+	},
+	{
+		"repeat",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			str, err := stringArg("repeat", args[0])
+			if err != nil {
+				return err
+			}
+			count, ok := ClampInt(args[1])
+			if !ok {
+				return newError("count of `repeat` must be INTEGER, got %s",
+					args[1].Type())
+			}
+			return RepeatString(rt, str, count)
+		}},
+	},
+	{
+		"padLeft",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			return padString(rt, "padLeft", true, args)
+		}},
+	},
+	{
+		"padRight",
+		&Builtin{RuntimeFn: func(rt Runtime, args ...Object) Object {
+			return padString(rt, "padRight", false, args)
+		}},
+	},
+	{
+		"chars",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			str, err := stringArg("chars", args[0])
+			if err != nil {
+				return err
+			}
+
+			elements := []Object{}
+			for _, r := range str {
+				elements = append(elements, &String{Value: string(r)})
+			}
+			return &Array{Elements: elements}
+		}},
+	},
+}
+
+// GetBuiltinByName returns builtin function named name
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+	return nil
+}
+
+// hashArg checks that argument of builtin function named name is hash
+func hashArg(name string, arg Object) (*Hash, *Error) {
+	hash, ok := arg.(*Hash)
+	if !ok {
+		return nil, newError("argument to `%s` must be HASH, got %s",
+			name, arg.Type())
+	}
+	return hash, nil
+}
+
+// hashKeyArg returns hash key of argument used as key of hash
+func hashKeyArg(arg Object) (HashKey, *Error) {
+	key, ok := arg.(Hashable)
+	if !ok {
+		return HashKey{}, newError("unusable as hash key: %s", arg.Type())
+	}
+	return key.HashKey(), nil
+}
+
+// copyHash returns shallow copy of hash keeping order of keys
+func copyHash(hash *Hash) *Hash {
+	newHash := NewHash(len(hash.Pairs))
+	for _, pair := range hash.OrderedPairs() {
+		newHash.Set(pair.Key.(Hashable).HashKey(), pair)
+	}
+	return newHash
+}
+
+// arrayArg checks that argument of builtin function named name is array
+func arrayArg(name string, arg Object) (*Array, *Error) {
+	arr, ok := arg.(*Array)
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got %s",
+			name, arg.Type())
+	}
+	return arr, nil
+}
+
+// apply calls function given to builtin function
+func apply(rt Runtime, fn Object, args ...Object) Object {
+	switch fn.Type() {
+	case FunctionObj, BuiltinObj:
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+	if rt == nil {
+		return newError("function can not be called outside of interpreter")
+	}
+	result := rt.Apply(fn, args...)
+	if result == nil {
+		// Function with empty body returns nothing
+		return NullValue
+	}
+	return result
+}
+
+// runtimeIO returns IO of rt. Process IO is used outside of interpreter.
+func runtimeIO(rt Runtime) *IO {
+	if rt == nil {
+		return DefaultIO()
+	}
+	return rt.IO()
+}
+
+// testElements reports whether any (expected is true) or all (expected is false) elements satisfy function
+func testElements(rt Runtime, name string, expected bool, args []Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	arr, err := arrayArg(name, args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, elem := range arr.Elements {
+		result := apply(rt, args[1], elem)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) == expected {
+			return nativeBool(expected)
+		}
+	}
+	return nativeBool(!expected)
+}
+
+// compareObjects returns TRUE if a is less than b. Numbers and strings can be compared.
+func compareObjects(a, b Object) Object {
+	if a.Type() == IntegerObj && b.Type() == IntegerObj {
+		return IntegerInfix("<", a, b)
+	}
+	switch a := a.(type) {
+	case *Integer, *BigInt, *Float:
+		if b.Type() == IntegerObj || b.Type() == FloatObj {
+			x, _ := numberArg("", a)
+			y, _ := numberArg("", b)
+			return nativeBool(x < y)
+		}
+	case *String:
+		if b, ok := b.(*String); ok {
+			return nativeBool(a.Value < b.Value)
+		}
+	}
+	return newError("cannot compare %s and %s", a.Type(), b.Type())
+}
+
+// equalObjects reports whether a and b are equal values.
+// Arrays and hashes are compared by their elements, and functions are compared by identity.
+func equalObjects(a, b Object) bool {
+	return equalNested(a, b, map[[2]Object]bool{})
+}
+
+// equalNested is equalObjects remembering pairs of arrays and hashes being compared.
+// Pair compared again inside itself (i.e. cyclic containers) is regarded as equal.
+func equalNested(a, b Object, seen map[[2]Object]bool) bool {
+	if a == b {
+		return true
+	}
+	switch a.(type) {
+	case *Array, *Hash:
+		if seen[[2]Object{a, b}] {
+			return true
+		}
+		seen[[2]Object{a, b}] = true
+	}
+
+	switch a := a.(type) {
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !equalNested(a.Elements[i], b.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			other, ok := b.Pairs[key]
+			if !ok || !equalNested(pair.Value, other.Value, seen) {
+				return false
+			}
+		}
+		return true
+	case Hashable:
+		b, ok := b.(Hashable)
+		return ok && a.HashKey() == b.HashKey()
+	default:
+		return a == b
+	}
+}
+
+// indexOf returns index of first element equal to obj or -1
+func indexOf(elements []Object, obj Object) int {
+	for i, elem := range elements {
+		if equalObjects(elem, obj) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sliceBounds returns start and end index of slice of sequence with length.
+// Negative index counts from end, and indexes out of range are clamped.
+func sliceBounds(name string, length int, args []Object) (int, int, *Error) {
+	bounds := []int{0, length}
+	for i, arg := range args {
+		idx, ok := ClampInt(arg)
+		if !ok {
+			return 0, 0, newError("index of `%s` must be INTEGER, got %s",
+				name, arg.Type())
+		}
+		if idx < 0 {
+			idx += int64(length)
+		}
+		if idx < 0 {
+			idx = 0
+		}
+		if idx > int64(length) {
+			idx = int64(length)
+		}
+		bounds[i] = int(idx)
+	}
+	if bounds[1] < bounds[0] {
+		bounds[1] = bounds[0]
+	}
+	return bounds[0], bounds[1], nil
+}
+
+func isError(obj Object) bool {
+	return obj != nil && obj.Type() == ErrorObj
+}
+
+func isTruthy(obj Object) bool {
+	switch obj {
+	case NullValue, FalseValue:
+		return false
+	default:
+		return true
+	}
+}
+
+func nativeBool(b bool) *Boolean {
+	if b {
+		return TrueValue
+	}
+	return FalseValue
+}
+
+// stringArg checks that argument of builtin function named name is string
+func stringArg(name string, arg Object) (string, *Error) {
+	str, ok := arg.(*String)
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got %s",
+			name, arg.Type())
+	}
+	return str.Value, nil
+}
+
+// stringArray converts strings to array of string objects
+func stringArray(strs []string) *Array {
+	elements := make([]Object, len(strs))
+	for i, str := range strs {
+		elements[i] = &String{Value: str}
+	}
+	return &Array{Elements: elements}
+}
+
+// mapString returns string converted by fn
+func mapString(name string, fn func(string) string, args []Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	str, err := stringArg(name, args[0])
+	if err != nil {
+		return err
+	}
+	return &String{Value: fn(str)}
+}
+
+// testString reports whether fn is satisfied by two string arguments
+func testString(name string, fn func(string, string) bool, args []Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	str, err := stringArg(name, args[0])
+	if err != nil {
+		return err
+	}
+	substr, err := stringArg(name, args[1])
+	if err != nil {
+		return err
+	}
+	return nativeBool(fn(str, substr))
+}
+
+// padString pads string with pad string (default is space) until it has width characters
+func padString(rt Runtime, name string, left bool, args []Object) Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError("wrong number of arguments. got=%d, want=2..3",
+			len(args))
+	}
+	str, err := stringArg(name, args[0])
+	if err != nil {
+		return err
+	}
+	width, ok := ClampInt(args[1])
+	if !ok {
+		return newError("width of `%s` must be INTEGER, got %s",
+			name, args[1].Type())
+	}
+	pad := " "
+	if len(args) == 3 {
+		if pad, err = stringArg(name, args[2]); err != nil {
+			return err
+		}
+		if pad == "" {
+			return newError("pad of `%s` must not be empty", name)
+		}
+	}
+
+	length := int64(utf8.RuneCountInString(str))
+	if width <= length {
+		return &String{Value: str}
+	}
+	// Pad string longer than one character is cut at width
+	n := width - length
+	padRunes := []rune(pad)
+	full := n / int64(len(padRunes))
+	tail := string(padRunes[:n%int64(len(padRunes))])
+	if full > maxRepeatedLength/int64(len(pad)) {
+		return newError("width of `%s` is too large: %s", name, args[1].Inspect())
+	}
+	size := full*int64(len(pad)) + int64(len(tail))
+	if rt != nil {
+		if err := rt.CheckAlloc(size); err != nil {
+			return err
+		}
+	}
+
+	var out strings.Builder
+	out.Grow(int(size) + len(str))
+	if !left {
+		out.WriteString(str)
+	}
+	for i := int64(0); i < full; i++ {
+		out.WriteString(pad)
+	}
+	out.WriteString(tail)
+	if left {
+		out.WriteString(str)
+	}
+	return &String{Value: out.String()}
+}
+
+// stringIndex returns index (in characters) of first substr in str or -1
+func stringIndex(str, substr string) int {
+	i := strings.Index(str, substr)
+	if i < 0 {
+		return -1
+	}
+	return utf8.RuneCountInString(str[:i])
+}
+
+// maxRepeatedLength is maximum bytes of string made by repetition
+const maxRepeatedLength = 1 << 30
+
+// RepeatString returns str repeated count times.
+// Memory limit of rt is checked before making the string (rt may be nil).
+func RepeatString(rt Runtime, str string, count int64) Object {
+	if count < 0 {
+		return newError("negative repeat count: %d", count)
+	}
+	if len(str) > 0 && count > maxRepeatedLength/int64(len(str)) {
+		return newError("repeated string is too long")
+	}
+	if rt != nil {
+		if err := rt.CheckAlloc(int64(len(str)) * count); err != nil {
+			return err
+		}
+	}
+	return &String{Value: strings.Repeat(str, int(count))}
+}
+
+// CharAt returns character at index (in characters) of str or null if index is out of range
+func CharAt(str string, index int64) Object {
+	if index < 0 {
+		return NullValue
+	}
+	for _, r := range str {
+		if index == 0 {
+			return &String{Value: string(r)}
+		}
+		index--
+	}
+	return NullValue
+}
+
+// numberArg converts integer or float argument of builtin function named name to float64
+func numberArg(name string, arg Object) (float64, *Error) {
+	switch arg := arg.(type) {
+	case *Integer:
+		return float64(arg.Value), nil
+	case *BigInt:
+		return arg.Float(), nil
+	case *Float:
+		return arg.Value, nil
+	default:
+		return 0, newError("argument to `%s` must be INTEGER or FLOAT, got %s",
+			name, arg.Type())
+	}
+}
+
+// roundNumber rounds float argument by round. Integer argument is returned as it is.
+func roundNumber(name string, round func(float64) float64, args []Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	switch arg := args[0].(type) {
+	case *Integer, *BigInt:
+		return arg
+	case *Float:
+		return &Float{Value: round(arg.Value)}
+	default:
+		return newError("argument to `%s` must be INTEGER or FLOAT, got %s",
+			name, arg.Type())
+	}
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,552 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// tagName is name of struct field tag used by FromGo and ToGo.
+// `monkey:"name"` renames field and `monkey:"-"` ignores field.
+const tagName = "monkey"
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// FromGo converts Go value to monkey object.
+// Integers (including *big.Int), floats, strings, bools, slices, arrays, maps, structs, pointers and funcs are supported.
+// Funcs are wrapped as Builtin converting arguments with ToGo and results with FromGo.
+// A func returning non-nil error as last result returns Error object.
+func FromGo(v interface{}) (Object, error) {
+	if v == nil {
+		return NullValue, nil
+	}
+	if obj, ok := v.(Object); ok {
+		return obj, nil
+	}
+	return fromGo(reflect.ValueOf(v), "", make(map[visit]bool))
+}
+
+// visit is reference value (pointer, map or slice) being converted by fromGo
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// fromGo converts v at path to monkey object.
+// visiting has reference values enclosing v to detect cyclic values.
+func fromGo(v reflect.Value, path string, visiting map[visit]bool) (Object, error) {
+	if v.IsValid() && v.Type().Implements(objectType) && !isNilValue(v) {
+		return v.Interface().(Object), nil
+	}
+	if v.IsValid() && v.Type() == bigIntType && !v.IsNil() {
+		return NewBigInt(new(big.Int).Set(v.Interface().(*big.Int))), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return NullValue, nil
+	case reflect.Bool:
+		if v.Bool() {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewBigInt(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return NullValue, nil
+			}
+			leave, err := enter(v, path, visiting)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			elem, err := fromGo(v.Index(i), fmt.Sprintf("%s[%d]", path, i), visiting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		leave, err := enter(v, path, visiting)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+
+		keys := v.MapKeys()
+		// Sort keys to convert map in the same order every time
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		hash := NewHash(len(keys))
+		for _, k := range keys {
+			elemPath := fmt.Sprintf("%s[%v]", path, k.Interface())
+			key, err := fromGo(k, elemPath, visiting)
+			if err != nil {
+				return nil, err
+			}
+			hashKey, ok := key.(Hashable)
+			if !ok {
+				return nil, conversionError(elemPath, "unusable as hash key: %s", key.Type())
+			}
+			value, err := fromGo(v.MapIndex(k), elemPath, visiting)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(hashKey.HashKey(), HashPair{Key: key, Value: value})
+		}
+		return hash, nil
+	case reflect.Struct:
+		fields := structFields(v.Type())
+		hash := NewHash(len(fields))
+		for _, f := range fields {
+			value, err := fromGo(v.FieldByIndex(f.index), path+"."+f.name, visiting)
+			if err != nil {
+				return nil, err
+			}
+			key := &String{Value: f.name}
+			hash.Set(key.HashKey(), HashPair{Key: key, Value: value})
+		}
+		return hash, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		if v.Kind() == reflect.Ptr {
+			leave, err := enter(v, path, visiting)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		return fromGo(v.Elem(), path, visiting)
+	case reflect.Func:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		return wrapFunc(v), nil
+	default:
+		return nil, conversionError(path, "unsupported Go type %s", v.Type())
+	}
+}
+
+// ToGo stores monkey object obj in Go value pointed by target.
+// If target points to interface{}, obj is converted to
+// int64 (*big.Int if out of range of int64), float64, string, bool, nil, []interface{} or map[string]interface{}
+// (map[interface{}]interface{} if hash has non-string keys).
+// Builtin can be stored in func value whose last result is error.
+func ToGo(obj Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("target must be non-nil pointer, got %T", target)
+	}
+	return toGo(obj, v.Elem(), "")
+}
+
+func toGo(obj Object, v reflect.Value, path string) error {
+	if obj == nil {
+		obj = NullValue
+	}
+	t := v.Type()
+
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		val, err := natural(obj, path)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			v.Set(reflect.Zero(t))
+		} else {
+			v.Set(reflect.ValueOf(val))
+		}
+		return nil
+	}
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+	if t == bigIntType && obj.Type() == IntegerObj {
+		v.Set(reflect.ValueOf(new(big.Int).Set(bigValue(obj))))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if obj == NullValue {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		p := reflect.New(t.Elem())
+		if err := toGo(obj, p.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	case reflect.Slice, reflect.Map, reflect.Func:
+		if obj == NullValue {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+	}
+
+	switch obj := obj.(type) {
+	case *Integer:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(obj.Value) {
+				return conversionError(path, "%d overflows %s", obj.Value, t)
+			}
+			v.SetInt(obj.Value)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if obj.Value < 0 || v.OverflowUint(uint64(obj.Value)) {
+				return conversionError(path, "%d overflows %s", obj.Value, t)
+			}
+			v.SetUint(uint64(obj.Value))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(obj.Value))
+			return nil
+		}
+	case *BigInt:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return conversionError(path, "%s overflows %s", obj.Inspect(), t)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if !obj.Value.IsUint64() || v.OverflowUint(obj.Value.Uint64()) {
+				return conversionError(path, "%s overflows %s", obj.Inspect(), t)
+			}
+			v.SetUint(obj.Value.Uint64())
+			return nil
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(obj.Float())
+			return nil
+		}
+	case *Float:
+		if t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64 {
+			v.SetFloat(obj.Value)
+			return nil
+		}
+	case *String:
+		if t.Kind() == reflect.String {
+			v.SetString(obj.Value)
+			return nil
+		}
+	case *Boolean:
+		if t.Kind() == reflect.Bool {
+			v.SetBool(obj.Value)
+			return nil
+		}
+	case *Array:
+		switch t.Kind() {
+		case reflect.Slice:
+			s := reflect.MakeSlice(t, len(obj.Elements), len(obj.Elements))
+			for i, elem := range obj.Elements {
+				if err := toGo(elem, s.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		case reflect.Array:
+			if len(obj.Elements) != t.Len() {
+				return conversionError(path, "cannot convert ARRAY of length %d to %s",
+					len(obj.Elements), t)
+			}
+			for i, elem := range obj.Elements {
+				if err := toGo(elem, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case *Hash:
+		switch t.Kind() {
+		case reflect.Map:
+			m := reflect.MakeMapWithSize(t, len(obj.Pairs))
+			for _, pair := range obj.OrderedPairs() {
+				elemPath := fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())
+				key := reflect.New(t.Key()).Elem()
+				if err := toGo(pair.Key, key, elemPath); err != nil {
+					return err
+				}
+				value := reflect.New(t.Elem()).Elem()
+				if err := toGo(pair.Value, value, elemPath); err != nil {
+					return err
+				}
+				m.SetMapIndex(key, value)
+			}
+			v.Set(m)
+			return nil
+		case reflect.Struct:
+			for _, f := range structFields(t) {
+				key := &String{Value: f.name}
+				pair, ok := obj.Pairs[key.HashKey()]
+				if !ok {
+					continue
+				}
+				if err := toGo(pair.Value, v.FieldByIndex(f.index), path+"."+f.name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case *Builtin:
+		if t.Kind() == reflect.Func {
+			if t.NumOut() == 0 || t.Out(t.NumOut()-1) != errorType {
+				// Errors of builtin function could not be returned
+				return conversionError(path, "cannot convert BUILTIN to %s: last result must be error", t)
+			}
+			v.Set(unwrapBuiltin(obj, t))
+			return nil
+		}
+	}
+
+	return conversionError(path, "cannot convert %s to %s", obj.Type(), t)
+}
+
+// natural returns Go value naturally corresponding to obj
+func natural(obj Object, path string) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+	case *BigInt:
+		return new(big.Int).Set(obj.Value), nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Null:
+		return nil, nil
+	case *Array:
+		s := make([]interface{}, len(obj.Elements))
+		for i, elem := range obj.Elements {
+			val, err := natural(elem, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = val
+		}
+		return s, nil
+	case *Hash:
+		stringKeys := true
+		for _, pair := range obj.Pairs {
+			if pair.Key.Type() != StringObj {
+				stringKeys = false
+				break
+			}
+		}
+		if stringKeys {
+			m := make(map[string]interface{}, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				key := pair.Key.(*String).Value
+				val, err := natural(pair.Value, fmt.Sprintf("%s[%s]", path, key))
+				if err != nil {
+					return nil, err
+				}
+				m[key] = val
+			}
+			return m, nil
+		}
+		m := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.OrderedPairs() {
+			elemPath := fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())
+			key, err := natural(pair.Key, elemPath)
+			if err != nil {
+				return nil, err
+			}
+			val, err := natural(pair.Value, elemPath)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = val
+		}
+		return m, nil
+	case *Error:
+		return nil, conversionError(path, "cannot convert ERROR: %s", obj.Message)
+	default:
+		// Functions have no Go representation
+		return obj, nil
+	}
+}
+
+// wrapFunc wraps Go func as builtin function
+func wrapFunc(fn reflect.Value) *Builtin {
+	t := fn.Type()
+	return &Builtin{Fn: func(args ...Object) Object {
+		numIn := t.NumIn()
+		if t.IsVariadic() {
+			if len(args) < numIn-1 {
+				return newError("wrong number of arguments. got=%d, want>=%d",
+					len(args), numIn-1)
+			}
+		} else if len(args) != numIn {
+			return newError("wrong number of arguments. got=%d, want=%d",
+				len(args), numIn)
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && i >= numIn-1 {
+				paramType = t.In(numIn - 1).Elem()
+			} else {
+				paramType = t.In(i)
+			}
+			param := reflect.New(paramType).Elem()
+			if err := toGo(arg, param, ""); err != nil {
+				return newError("argument %d: %s", i+1, err)
+			}
+			in[i] = param
+		}
+
+		out := fn.Call(in)
+		if n := len(out); n > 0 && t.Out(n-1) == errorType {
+			if err := out[n-1]; !err.IsNil() {
+				return newError("%s", err.Interface().(error))
+			}
+			out = out[:n-1]
+		}
+
+		results := make([]Object, len(out))
+		for i, o := range out {
+			obj, err := fromGo(o, "", make(map[visit]bool))
+			if err != nil {
+				return newError("result %d: %s", i+1, err)
+			}
+			results[i] = obj
+		}
+		switch len(results) {
+		case 0:
+			return NullValue
+		case 1:
+			return results[0]
+		default:
+			return &Array{Elements: results}
+		}
+	}}
+}
+
+// unwrapBuiltin makes Go func of type t calling builtin function.
+// Last result of t must be error, which reports errors of conversion and builtin function.
+func unwrapBuiltin(b *Builtin, t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, t.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(t.Out(i))
+		}
+		fail := func(err error) []reflect.Value {
+			out[len(out)-1] = reflect.ValueOf(&err).Elem()
+			return out
+		}
+
+		var values []reflect.Value
+		for i, v := range in {
+			if t.IsVariadic() && i == len(in)-1 {
+				for j := 0; j < v.Len(); j++ {
+					values = append(values, v.Index(j))
+				}
+				break
+			}
+			values = append(values, v)
+		}
+		args := make([]Object, len(values))
+		for i, v := range values {
+			arg, err := fromGo(v, "", make(map[visit]bool))
+			if err != nil {
+				return fail(fmt.Errorf("argument %d: %s", i+1, err))
+			}
+			args[i] = arg
+		}
+
+		result := b.Call(nil, args...)
+		if err, ok := result.(*Error); ok {
+			return fail(err)
+		}
+		if len(out) > 1 {
+			v := reflect.New(t.Out(0)).Elem()
+			if err := toGo(result, v, ""); err != nil {
+				return fail(err)
+			}
+			out[0] = v
+		}
+		return out
+	})
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields returns exported fields of struct type t with their names in monkey
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup(tagName); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name: name, index: f.Index})
+	}
+	return fields
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
+
+// enter marks reference value v as being converted.
+// It returns func unmarking v, or error if v is being converted already by enclosing value (cyclic value).
+func enter(v reflect.Value, path string, visiting map[visit]bool) (func(), error) {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if visiting[key] {
+		return nil, conversionError(path, "cyclic value of type %s", v.Type())
+	}
+	visiting[key] = true
+	return func() { delete(visiting, key) }, nil
+}
+
+func conversionError(path, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	return errors.New(msg)
+}
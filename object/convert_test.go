@@ -0,0 +1,289 @@
+package object
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type user struct {
+	Name   string `monkey:"name"`
+	Age    int    `monkey:"age"`
+	Admin  bool
+	Secret string `monkey:"-"`
+	hidden int
+}
+
+func TestFromGo(t *testing.T) {
+	shared := 5
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{uint64(1 << 63), "9223372036854775808"},
+		{new(big.Int).Lsh(big.NewInt(1), 64), "18446744073709551616"},
+		{"monkey", "monkey"},
+		{true, "true"},
+		{[]int{1, 2, 3}, "[1,2,3]"},
+		{[2]string{"a", "b"}, "[a,b]"},
+		{map[string]int{"one": 1}, "{one: 1}"},
+		{&user{Name: "x", Age: 3, Secret: "s"}, ""},
+		{(*user)(nil), "null"},
+		{[]*int{&shared, &shared}, "[5,5]"},
+		{&Integer{Value: 5}, "5"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Fatalf("FromGo(%#v) returned error: %s", tt.input, err)
+		}
+		if tt.expected != "" && obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) wrong. want=%s, got=%s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	obj, _ := FromGo(user{Name: "x", Age: 3, Secret: "s"})
+	hash, ok := obj.(*Hash)
+	if !ok {
+		t.Fatalf("struct is not converted to Hash. got=%T", obj)
+	}
+	for key, expected := range map[string]string{"name": "x", "age": "3", "Admin": "false"} {
+		pair, ok := hash.Pairs[(&String{Value: key}).HashKey()]
+		if !ok {
+			t.Errorf("field %q not found", key)
+			continue
+		}
+		if pair.Value.Inspect() != expected {
+			t.Errorf("field %q wrong. want=%s, got=%s", key, expected, pair.Value.Inspect())
+		}
+	}
+	if len(hash.Pairs) != 3 {
+		t.Errorf("ignored fields are converted. got=%d pairs", len(hash.Pairs))
+	}
+}
+
+type node struct {
+	Value int
+	Next  *node
+}
+
+func TestFromGoErrors(t *testing.T) {
+	cyclicNode := &node{Value: 1}
+	cyclicNode.Next = &node{Value: 2, Next: cyclicNode}
+	cyclicSlice := []interface{}{1, nil}
+	cyclicSlice[1] = cyclicSlice
+	cyclicMap := map[string]interface{}{}
+	cyclicMap["m"] = cyclicMap
+
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{[]interface{}{1, make(chan int)}, "[1]: unsupported Go type chan int"},
+		{map[string][]complex64{"c": {1}}, "[c][0]: unsupported Go type complex64"},
+		{cyclicNode, ".Next.Next: cyclic value of type *object.node"},
+		{cyclicSlice, "[1]: cyclic value of type []interface {}"},
+		{cyclicMap, "[m]: cyclic value of type map[string]interface {}"},
+	}
+
+	for _, tt := range tests {
+		_, err := FromGo(tt.input)
+		if err == nil {
+			t.Errorf("FromGo(%#v) did not return error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestToGo(t *testing.T) {
+	var i int
+	if err := ToGo(&Integer{Value: 3}, &i); err != nil || i != 3 {
+		t.Errorf("ToGo to int failed. got=%d (%v)", i, err)
+	}
+
+	var s []string
+	arr := &Array{Elements: []Object{&String{Value: "a"}, &String{Value: "b"}}}
+	if err := ToGo(arr, &s); err != nil || !reflect.DeepEqual(s, []string{"a", "b"}) {
+		t.Errorf("ToGo to []string failed. got=%v (%v)", s, err)
+	}
+
+	obj, _ := FromGo(map[string]interface{}{"name": "x", "age": 3, "Admin": true, "extra": 1})
+	var u user
+	if err := ToGo(obj, &u); err != nil {
+		t.Fatalf("ToGo to struct failed: %s", err)
+	}
+	if u != (user{Name: "x", Age: 3, Admin: true}) {
+		t.Errorf("ToGo to struct wrong. got=%+v", u)
+	}
+
+	var m map[string]int
+	if err := ToGo(&Hash{Pairs: map[HashKey]HashPair{}}, &m); err != nil || m == nil {
+		t.Errorf("ToGo to map failed. got=%v (%v)", m, err)
+	}
+
+	var v interface{}
+	if err := ToGo(obj, &v); err != nil {
+		t.Fatalf("ToGo to interface{} failed: %s", err)
+	}
+	expected := map[string]interface{}{"name": "x", "age": int64(3), "Admin": true, "extra": int64(1)}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("ToGo to interface{} wrong. want=%v, got=%v", expected, v)
+	}
+
+	var p *int
+	if err := ToGo(NullValue, &p); err != nil || p != nil {
+		t.Errorf("ToGo null to pointer failed. got=%v (%v)", p, err)
+	}
+	if err := ToGo(&Integer{Value: 1}, &p); err != nil || p == nil || *p != 1 {
+		t.Errorf("ToGo to pointer failed. got=%v (%v)", p, err)
+	}
+
+	var u64 uint64
+	if err := ToGo(&BigInt{Value: new(big.Int).SetUint64(1 << 63)}, &u64); err != nil || u64 != 1<<63 {
+		t.Errorf("ToGo big integer to uint64 failed. got=%d (%v)", u64, err)
+	}
+	var b *big.Int
+	if err := ToGo(&Integer{Value: 7}, &b); err != nil || b == nil || b.Int64() != 7 {
+		t.Errorf("ToGo to *big.Int failed. got=%v (%v)", b, err)
+	}
+
+	var o Object
+	if err := ToGo(TrueValue, &o); err != nil || o != TrueValue {
+		t.Errorf("ToGo to Object failed. got=%v (%v)", o, err)
+	}
+}
+
+func TestToGoErrors(t *testing.T) {
+	var i8 int8
+	var u uint
+	var ss []string
+	var arr [3]int
+	var u2 user
+
+	tests := []struct {
+		obj      Object
+		target   interface{}
+		expected string
+	}{
+		{&Integer{Value: 1}, i8, "target must be non-nil pointer, got int8"},
+		{&Integer{Value: 300}, &i8, "300 overflows int8"},
+		{&Integer{Value: -1}, &u, "-1 overflows uint"},
+		{&BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 64)}, &u, "18446744073709551616 overflows uint"},
+		{&String{Value: "x"}, &i8, "cannot convert STRING to int8"},
+		{&Array{Elements: []Object{&String{Value: "a"}, TrueValue}}, &ss, "[1]: cannot convert BOOLEAN to string"},
+		{&Array{Elements: []Object{}}, &arr, "cannot convert ARRAY of length 0 to [3]int"},
+		{mustFromGoValue(map[string]interface{}{"age": "old"}), &u2, ".age: cannot convert STRING to int"},
+	}
+
+	for _, tt := range tests {
+		err := ToGo(tt.obj, tt.target)
+		if err == nil {
+			t.Errorf("ToGo(%s) did not return error", tt.obj.Inspect())
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestFromGoFunc(t *testing.T) {
+	obj, err := FromGo(func(a, b int) int { return a + b })
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+	add, ok := obj.(*Builtin)
+	if !ok {
+		t.Fatalf("func is not converted to Builtin. got=%T", obj)
+	}
+
+	if result := add.Fn(&Integer{Value: 1}, &Integer{Value: 2}); result.Inspect() != "3" {
+		t.Errorf("wrong result. want=3, got=%s", result.Inspect())
+	}
+	if result := add.Fn(&Integer{Value: 1}); result.Inspect() != "ERROR: wrong number of arguments. got=1, want=2" {
+		t.Errorf("wrong arity error. got=%s", result.Inspect())
+	}
+	if result := add.Fn(&Integer{Value: 1}, &String{Value: "2"}); result.Inspect() != "ERROR: argument 2: cannot convert STRING to int" {
+		t.Errorf("wrong argument error. got=%s", result.Inspect())
+	}
+
+	obj, _ = FromGo(func(s string) (string, error) {
+		if s == "" {
+			return "", errors.New("empty")
+		}
+		return strings.ToUpper(s), nil
+	})
+	upper := obj.(*Builtin)
+	if result := upper.Fn(&String{Value: "abc"}); result.Inspect() != "ABC" {
+		t.Errorf("wrong result. want=ABC, got=%s", result.Inspect())
+	}
+	if result := upper.Fn(&String{Value: ""}); result.Inspect() != "ERROR: empty" {
+		t.Errorf("wrong error. got=%s", result.Inspect())
+	}
+
+	obj, _ = FromGo(func(xs ...int) (int, int) { return len(xs), 0 })
+	count := obj.(*Builtin)
+	if result := count.Fn(&Integer{Value: 1}, &Integer{Value: 1}); result.Inspect() != "[2,0]" {
+		t.Errorf("wrong result. want=[2,0], got=%s", result.Inspect())
+	}
+}
+
+func TestToGoFunc(t *testing.T) {
+	double, _ := FromGo(func(x int) int { return x * 2 })
+
+	var fn func(int) (int, error)
+	if err := ToGo(double, &fn); err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+	result, err := fn(4)
+	if err != nil || result != 8 {
+		t.Errorf("wrong result. want=8, got=%d (%v)", result, err)
+	}
+
+	fail := &Builtin{Fn: func(args ...Object) Object { return newError("failed") }}
+	if err := ToGo(fail, &fn); err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+	if _, err := fn(1); err == nil || err.Error() != "failed" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	str := &Builtin{Fn: func(args ...Object) Object { return &String{Value: "x"} }}
+	if err := ToGo(str, &fn); err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+	if _, err := fn(1); err == nil || err.Error() != "cannot convert STRING to int" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	var fnc func(chan int) error
+	if err := ToGo(str, &fnc); err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+	if err := fnc(nil); err == nil || err.Error() != "argument 1: unsupported Go type chan int" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	var noError func(int) int
+	err = ToGo(double, &noError)
+	if err == nil || err.Error() != "cannot convert BUILTIN to func(int) int: last result must be error" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func mustFromGoValue(v interface{}) Object {
+	obj, err := FromGo(v)
+	if err != nil {
+		panic(err)
+	}
+	return obj
+}
@@ -0,0 +1,288 @@
+package object
+
+import (
+	"hash/fnv"
+	"math"
+	"math/big"
+)
+
+// maxBigIntBits is maximum bit length of integer made by '**' and '<<'
+const maxBigIntBits = 1 << 20
+
+// bigIntKeyType is type of hash key for BigInt.
+// BigInt never equals Integer, so it does not share keys with Integer.
+const bigIntKeyType ObjectType = "BIG_INTEGER"
+
+// BigInt is integer object out of range of int64.
+// Integers in range of int64 are always Integer, so BigInt and Integer are never equal.
+type BigInt struct {
+	Value *big.Int
+}
+
+// Type returns 'INTEGER' (the same as Integer)
+func (b *BigInt) Type() ObjectType {
+	return IntegerObj
+}
+
+// Inspect returns integer value
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+// HashKey returns key for big integer object
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	if b.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(b.Value.Bytes())
+	return HashKey{Type: bigIntKeyType, Value: h.Sum64()}
+}
+
+// Float returns nearest float64 value
+func (b *BigInt) Float() float64 {
+	return bigFloat(b.Value)
+}
+
+// NewBigInt returns Integer if v is in range of int64, otherwise BigInt
+func NewBigInt(v *big.Int) Object {
+	if v.IsInt64() {
+		return &Integer{Value: v.Int64()}
+	}
+	return &BigInt{Value: v}
+}
+
+// bigValue returns value of integer object (Integer or BigInt)
+func bigValue(obj Object) *big.Int {
+	if b, ok := obj.(*BigInt); ok {
+		return b.Value
+	}
+	return big.NewInt(obj.(*Integer).Value)
+}
+
+// IntegerInfix applies operator to integer objects (Integer or BigInt).
+// Result out of range of int64 is promoted to BigInt instead of overflowing.
+func IntegerInfix(operator string, left, right Object) Object {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	if lok && rok {
+		if result := smallIntegerInfix(operator, l.Value, r.Value); result != nil {
+			return result
+		}
+	}
+	return bigIntegerInfix(operator, left, right)
+}
+
+// smallIntegerInfix applies operator to int64 values.
+// It returns nil if result overflows or operator is not supported.
+func smallIntegerInfix(operator string, x, y int64) Object {
+	switch operator {
+	case "+":
+		if z := x + y; (z > x) == (y > 0) {
+			return &Integer{Value: z}
+		}
+	case "-":
+		if z := x - y; (z < x) == (y > 0) {
+			return &Integer{Value: z}
+		}
+	case "*":
+		if x == 0 || y == 0 {
+			return &Integer{Value: 0}
+		}
+		if z := x * y; z/y == x && !(x == -1 && y == math.MinInt64) && !(y == -1 && x == math.MinInt64) {
+			return &Integer{Value: z}
+		}
+	case "/", "%":
+		if y == 0 {
+			return newError("division by zero: %d %s %d", x, operator, y)
+		}
+		if x == math.MinInt64 && y == -1 {
+			// Quotient overflows
+			return nil
+		}
+		if operator == "/" {
+			return &Integer{Value: x / y}
+		}
+		return &Integer{Value: x % y}
+	case "**":
+		if y < 0 {
+			return &Float{Value: math.Pow(float64(x), float64(y))}
+		}
+		if z, ok := powInt64(x, y); ok {
+			return &Integer{Value: z}
+		}
+	case "<":
+		return nativeBool(x < y)
+	case ">":
+		return nativeBool(x > y)
+	case "<=":
+		return nativeBool(x <= y)
+	case ">=":
+		return nativeBool(x >= y)
+	case "==":
+		return nativeBool(x == y)
+	case "!=":
+		return nativeBool(x != y)
+	case "&":
+		return &Integer{Value: x & y}
+	case "|":
+		return &Integer{Value: x | y}
+	case "^":
+		return &Integer{Value: x ^ y}
+	case "<<":
+		if y < 0 {
+			return newError("negative shift count: %d %s %d", x, operator, y)
+		}
+		if y < 63 && (x<<uint64(y))>>uint64(y) == x {
+			return &Integer{Value: x << uint64(y)}
+		}
+		if x == 0 {
+			return &Integer{Value: 0}
+		}
+	case ">>":
+		if y < 0 {
+			return newError("negative shift count: %d %s %d", x, operator, y)
+		}
+		return &Integer{Value: x >> uint64(y)}
+	}
+	return nil
+}
+
+// bigIntegerInfix applies operator to integer objects by arbitrary-precision arithmetic
+func bigIntegerInfix(operator string, left, right Object) Object {
+	x := bigValue(left)
+	y := bigValue(right)
+
+	switch operator {
+	case "+":
+		return NewBigInt(new(big.Int).Add(x, y))
+	case "-":
+		return NewBigInt(new(big.Int).Sub(x, y))
+	case "*":
+		return NewBigInt(new(big.Int).Mul(x, y))
+	case "/", "%":
+		if y.Sign() == 0 {
+			return newError("division by zero: %s %s %s", x, operator, y)
+		}
+		// Quotient is truncated toward zero like int64 division
+		if operator == "/" {
+			return NewBigInt(new(big.Int).Quo(x, y))
+		}
+		return NewBigInt(new(big.Int).Rem(x, y))
+	case "**":
+		if y.Sign() < 0 {
+			return &Float{Value: math.Pow(bigFloat(x), bigFloat(y))}
+		}
+		if x.CmpAbs(big.NewInt(1)) <= 0 {
+			// 0, 1 and -1 do not grow with large exponent
+			if x.Sign() < 0 && y.Bit(0) == 0 {
+				return &Integer{Value: 1}
+			}
+			return NewBigInt(new(big.Int).Set(x))
+		}
+		if !y.IsInt64() || y.Int64() > maxBigIntBits/int64(x.BitLen()) {
+			return newError("integer overflow: %s %s %s is too large", x, operator, y)
+		}
+		return NewBigInt(new(big.Int).Exp(x, y, nil))
+	case "<":
+		return nativeBool(x.Cmp(y) < 0)
+	case ">":
+		return nativeBool(x.Cmp(y) > 0)
+	case "<=":
+		return nativeBool(x.Cmp(y) <= 0)
+	case ">=":
+		return nativeBool(x.Cmp(y) >= 0)
+	case "==":
+		return nativeBool(x.Cmp(y) == 0)
+	case "!=":
+		return nativeBool(x.Cmp(y) != 0)
+	case "&":
+		return NewBigInt(new(big.Int).And(x, y))
+	case "|":
+		return NewBigInt(new(big.Int).Or(x, y))
+	case "^":
+		return NewBigInt(new(big.Int).Xor(x, y))
+	case "<<", ">>":
+		if y.Sign() < 0 {
+			return newError("negative shift count: %s %s %s", x, operator, y)
+		}
+		if operator == ">>" {
+			if !y.IsInt64() || y.Int64() > int64(x.BitLen()) {
+				// All bits are shifted out
+				return &Integer{Value: int64(x.Sign() >> 1)}
+			}
+			return NewBigInt(new(big.Int).Rsh(x, uint(y.Int64())))
+		}
+		if x.Sign() == 0 {
+			return &Integer{Value: 0}
+		}
+		if !y.IsInt64() || y.Int64() > maxBigIntBits-int64(x.BitLen()) {
+			return newError("integer overflow: %s %s %s is too large", x, operator, y)
+		}
+		return NewBigInt(new(big.Int).Lsh(x, uint(y.Int64())))
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// NegateInteger returns -obj of integer object (Integer or BigInt)
+func NegateInteger(obj Object) Object {
+	if i, ok := obj.(*Integer); ok && i.Value != math.MinInt64 {
+		return &Integer{Value: -i.Value}
+	}
+	return NewBigInt(new(big.Int).Neg(bigValue(obj)))
+}
+
+// BitNotInteger returns ~obj of integer object (Integer or BigInt)
+func BitNotInteger(obj Object) Object {
+	if i, ok := obj.(*Integer); ok {
+		return &Integer{Value: ^i.Value}
+	}
+	return NewBigInt(new(big.Int).Not(bigValue(obj)))
+}
+
+// powInt64 returns base**exp (exp >= 0). It reports false if result overflows.
+func powInt64(base, exp int64) (int64, bool) {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			r, ok := smallIntegerInfix("*", result, base).(*Integer)
+			if !ok {
+				return 0, false
+			}
+			result = r.Value
+		}
+		exp >>= 1
+		if exp > 0 {
+			b, ok := smallIntegerInfix("*", base, base).(*Integer)
+			if !ok {
+				return 0, false
+			}
+			base = b.Value
+		}
+	}
+	return result, true
+}
+
+func bigFloat(v *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(v).Float64()
+	return f
+}
+
+// ClampInt returns value of integer object as int64.
+// BigInt is clamped to range of int64, which is beyond any index or length.
+// It reports false if obj is not integer.
+func ClampInt(obj Object) (int64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, true
+	case *BigInt:
+		if obj.Value.Sign() < 0 {
+			return math.MinInt64, true
+		}
+		return math.MaxInt64, true
+	default:
+		return 0, false
+	}
+}
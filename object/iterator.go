@@ -0,0 +1,113 @@
+package object
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Range is sequence of integers from Start to End (exclusive) by Step.
+// Its elements are computed on iteration instead of being allocated.
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64 // Not zero
+}
+
+// Type returns 'RANGE'
+func (r *Range) Type() ObjectType {
+	return RangeObj
+}
+
+// Inspect returns range (e.g. 'range(0, 10, 1)')
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// Len returns number of elements in range
+func (r *Range) Len() int64 {
+	// Differences are computed in uint64 not to overflow
+	if r.Step > 0 && r.Start < r.End {
+		return int64((uint64(r.End)-uint64(r.Start)-1)/uint64(r.Step) + 1)
+	}
+	if r.Step < 0 && r.Start > r.End {
+		return int64((uint64(r.Start)-uint64(r.End)-1)/(-uint64(r.Step)) + 1)
+	}
+	return 0
+}
+
+// Iterator iterates elements of array, hash, string or range by 'for ... in' loop
+type Iterator struct {
+	// Keyed tells that loop with one variable iterates keys instead of values (hash)
+	Keyed bool
+
+	next func() (key, value Object, ok bool)
+}
+
+// Type returns 'ITERATOR'
+func (it *Iterator) Type() ObjectType {
+	return IteratorObj
+}
+
+// Inspect returns 'iterator'
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
+
+// Next returns next index and element (key and value of hash).
+// It reports false if all elements were iterated.
+func (it *Iterator) Next() (key, value Object, ok bool) {
+	return it.next()
+}
+
+// Iterate returns iterator of obj or nil if obj is not iterable.
+// Array is iterated until its current end, and hash iterates keys it has when iteration starts.
+func Iterate(obj Object) *Iterator {
+	switch obj := obj.(type) {
+	case *Array:
+		i := 0
+		return &Iterator{next: func() (Object, Object, bool) {
+			if i >= len(obj.Elements) {
+				return nil, nil, false
+			}
+			i++
+			return &Integer{Value: int64(i - 1)}, obj.Elements[i-1], true
+		}}
+
+	case *Hash:
+		pairs := obj.OrderedPairs()
+		i := 0
+		return &Iterator{Keyed: true, next: func() (Object, Object, bool) {
+			if i >= len(pairs) {
+				return nil, nil, false
+			}
+			i++
+			return pairs[i-1].Key, pairs[i-1].Value, true
+		}}
+
+	case *String:
+		offset, i := 0, 0
+		return &Iterator{next: func() (Object, Object, bool) {
+			if offset >= len(obj.Value) {
+				return nil, nil, false
+			}
+			r, size := utf8.DecodeRuneInString(obj.Value[offset:])
+			offset += size
+			i++
+			return &Integer{Value: int64(i - 1)}, &String{Value: string(r)}, true
+		}}
+
+	case *Range:
+		var i int64
+		length := obj.Len()
+		return &Iterator{next: func() (Object, Object, bool) {
+			if i >= length {
+				return nil, nil, false
+			}
+			i++
+			return &Integer{Value: i - 1}, &Integer{Value: obj.Start + (i-1)*obj.Step}, true
+		}}
+
+	default:
+		return nil
+	}
+}
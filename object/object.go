@@ -4,9 +4,17 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/x-color/monkey/ast"
+	"github.com/x-color/monkey/code"
+	"github.com/x-color/monkey/token"
 )
 
 // ObjectType is object type (int, bool, null)
@@ -15,18 +23,57 @@ type ObjectType string
 // BuiltinFunction is builtin function
 type BuiltinFunction func(args ...Object) Object
 
+// RuntimeFunction is builtin function using runtime which calls it
+type RuntimeFunction func(rt Runtime, args ...Object) Object
+
+// Runtime is interpreter (evaluator or vm) calling builtin function
+type Runtime interface {
+	// Apply calls function object with args. Error is returned as *Error.
+	Apply(fn Object, args ...Object) Object
+	// IO returns standard input and outputs of program
+	IO() *IO
+	// CheckAlloc returns *Error if allocating size bytes exceeds limit of memory, otherwise nil
+	CheckAlloc(size int64) Object
+}
+
+// IO is standard input and outputs used by builtin functions
+type IO struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// DefaultIO returns IO of process (os.Stdin, os.Stdout and os.Stderr)
+func DefaultIO() *IO {
+	return &IO{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+}
+
 // Object types
 const (
 	IntegerObj     = "INTEGER"
+	FloatObj       = "FLOAT"
 	StringObj      = "STRING"
 	BooleanObj     = "BOOLEAN"
 	NullObj        = "NULL"
 	ReturnValueObj = "RETURN_VALUE"
+	BreakObj       = "BREAK"
+	ContinueObj    = "CONTINUE"
 	ErrorObj       = "ERROR"
 	FunctionObj    = "FUNCTION"
 	BuiltinObj     = "BUILTIN"
 	ArrayObj       = "ARRAY"
 	HashObj        = "HASH"
+	RangeObj       = "RANGE"
+	IteratorObj    = "ITERATOR"
+
+	CompiledFunctionObj = "COMPILED_FUNCTION"
+)
+
+// Constant boolean and null objects shared by evaluator and vm
+var (
+	NullValue  = &Null{}
+	TrueValue  = &Boolean{Value: true}
+	FalseValue = &Boolean{Value: false}
 )
 
 // Object is object interface
@@ -35,9 +82,19 @@ type Object interface {
 	Inspect() string
 }
 
-// Builtin is builtin function object
+// Builtin is builtin function object.
+// RuntimeFn is called instead of Fn if it is set.
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn        BuiltinFunction
+	RuntimeFn RuntimeFunction
+}
+
+// Call calls builtin function with args. rt is nil if it is called outside of interpreter.
+func (b *Builtin) Call(rt Runtime, args ...Object) Object {
+	if b.RuntimeFn != nil {
+		return b.RuntimeFn(rt, args...)
+	}
+	return b.Fn(args...)
 }
 
 // Type returns 'BUILTIN'
@@ -65,6 +122,26 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+// Float is floating-point number object
+type Float struct {
+	Value float64
+}
+
+// Type returns 'FLOAT'
+func (f *Float) Type() ObjectType {
+	return FloatObj
+}
+
+// Inspect returns value of float object ('3.14', '1.0', '1e-09', ...)
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		// Distinguish from integer (e.g. '1.0' instead of '1')
+		s += ".0"
+	}
+	return s
+}
+
 // String is string object
 type String struct {
 	Value string
@@ -124,9 +201,45 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Break is signal of 'break' statement exiting loop
+type Break struct{}
+
+// Type returns 'BREAK'
+func (b *Break) Type() ObjectType {
+	return BreakObj
+}
+
+// Inspect returns 'break'
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+// Continue is signal of 'continue' statement starting next iteration of loop
+type Continue struct{}
+
+// Type returns 'CONTINUE'
+func (c *Continue) Type() ObjectType {
+	return ContinueObj
+}
+
+// Inspect returns 'continue'
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
 // Error is error object
 type Error struct {
 	Message string
+	Pos     token.Position // Start position of node raising error
+	End     token.Position // End position of node raising error
+	Stack   []Frame        // Function calls active when error was raised (innermost first)
+	Cause   error          // Go error causing this error (e.g. exceeded limit), if any
+}
+
+// Frame is function call recorded in error stack
+type Frame struct {
+	Function string         // Called function name
+	Pos      token.Position // Position of calling expression
 }
 
 // Type returns 'ERROR'
@@ -139,8 +252,56 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Error returns error message
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns Go error causing this error
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// StackTrace returns error message and called functions (innermost first)
+//
+//	ERROR: identifier not found: x
+//		at add (main.mky:2:12)
+//		at <main> (main.mky:5:1)
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Inspect())
+	out.WriteString("\n")
+
+	pos := e.Pos
+	prev, repeated := "", 0
+	for _, f := range e.Stack {
+		line := fmt.Sprintf("\tat %s (%s)\n", f.Function, pos)
+		pos = f.Pos
+		if line == prev {
+			// Frames of deep recursion are collapsed
+			repeated++
+			continue
+		}
+		writeRepeated(&out, repeated)
+		out.WriteString(line)
+		prev, repeated = line, 0
+	}
+	writeRepeated(&out, repeated)
+	out.WriteString(fmt.Sprintf("\tat <main> (%s)\n", pos))
+
+	return out.String()
+}
+
+func writeRepeated(out *bytes.Buffer, repeated int) {
+	if repeated > 0 {
+		out.WriteString(fmt.Sprintf("\t... previous frame repeated %d times\n", repeated))
+	}
+}
+
 // Function is function object
 type Function struct {
+	Name       string // Name bound by 'let' statement (empty if anonymous)
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -151,12 +312,25 @@ func (f *Function) Type() ObjectType {
 	return FunctionObj
 }
 
+// FunctionName returns function name or '<anonymous>'
+func (f *Function) FunctionName() string {
+	if f.Name == "" {
+		return "<anonymous>"
+	}
+	return f.Name
+}
+
 // Inspect returns definition of function
 func (f *Function) Inspect() string {
+	return InspectFunction(f.Parameters, f.Body)
+}
+
+// InspectFunction returns definition of function
+func InspectFunction(parameters []*ast.Identifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
+	for _, p := range parameters {
 		params = append(params, p.String())
 	}
 
@@ -164,12 +338,77 @@ func (f *Function) Inspect() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n")
 
 	return out.String()
 }
 
+// CompiledFunction is function compiled to bytecode
+type CompiledFunction struct {
+	Name          string // Name bound by 'let' statement (empty if anonymous)
+	Instructions  code.Instructions
+	SourceMap     code.SourceMap
+	NumLocals     int
+	NumParameters int
+	Captures      []Capture // Free variables captured when closure is made
+	LocalNames    []string  // Names of local variables (index is local index)
+	FreeNames     []string  // Names of free variables (index is free index)
+	Definition    string    // Function definition shown by Inspect
+}
+
+// Capture tells where closure captures free variable from
+type Capture struct {
+	Local bool // Whether captured from local of enclosing function (or its free variable)
+	Index int  // Index of local or free variable in enclosing function
+}
+
+// Type returns 'COMPILED_FUNCTION'
+func (cf *CompiledFunction) Type() ObjectType {
+	return CompiledFunctionObj
+}
+
+// Inspect returns definition of function
+func (cf *CompiledFunction) Inspect() string {
+	return cf.Definition
+}
+
+// FunctionName returns function name or '<anonymous>'
+func (cf *CompiledFunction) FunctionName() string {
+	if cf.Name == "" {
+		return "<anonymous>"
+	}
+	return cf.Name
+}
+
+// Upvalue is variable captured by closure
+type Upvalue struct {
+	Ref   *Object // Captured variable (stack slot until its frame returns)
+	Value Object  // Captured variable after its frame returned
+}
+
+// Close moves captured variable from stack into upvalue
+func (uv *Upvalue) Close() {
+	uv.Value = *uv.Ref
+	uv.Ref = &uv.Value
+}
+
+// Closure is compiled function with captured free variables
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Upvalue
+}
+
+// Type returns 'FUNCTION' (closure is function in monkey programs)
+func (c *Closure) Type() ObjectType {
+	return FunctionObj
+}
+
+// Inspect returns definition of function
+func (c *Closure) Inspect() string {
+	return c.Fn.Inspect()
+}
+
 // Array is array object
 type Array struct {
 	Elements []Object
@@ -180,13 +419,23 @@ func (ao *Array) Type() ObjectType {
 	return ArrayObj
 }
 
-// Inspect returns array
+// Inspect returns array. Array containing itself is shown as [...] inside.
 func (ao *Array) Inspect() string {
+	return ao.inspect(map[Object]bool{})
+}
+
+func (ao *Array) inspect(seen map[Object]bool) string {
+	if seen[ao] {
+		return "[...]"
+	}
+	seen[ao] = true
+	defer delete(seen, ao)
+
 	var out bytes.Buffer
 
 	elements := []string{}
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspectElement(e, seen))
 	}
 
 	out.WriteString("[")
@@ -196,6 +445,19 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+// inspectElement returns Inspect of element of array or hash.
+// Arrays and hashes in seen are being inspected, so they are not inspected again.
+func inspectElement(obj Object, seen map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.inspect(seen)
+	case *Hash:
+		return obj.inspect(seen)
+	default:
+		return obj.Inspect()
+	}
+}
+
 // Hashable is hashable object's interface
 type Hashable interface {
 	HashKey() HashKey
@@ -225,6 +487,19 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey returns key for float object.
+// Float equal to integer has the same key as the integer (e.g. 1.0 and 1).
+func (f *Float) HashKey() HashKey {
+	if f.Value >= math.MinInt64 && f.Value < math.MaxInt64 && f.Value == math.Trunc(f.Value) {
+		return (&Integer{Value: int64(f.Value)}).HashKey()
+	}
+	if !math.IsInf(f.Value, 0) && f.Value == math.Trunc(f.Value) {
+		v, _ := big.NewFloat(f.Value).Int(nil)
+		return (&BigInt{Value: v}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 // HashKey returns key for string object
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
@@ -238,9 +513,97 @@ type HashPair struct {
 	Value Object
 }
 
-// Hash is associative array object
+// Hash is associative array object keeping insertion order of keys.
+// Pairs should be added and removed by Set and Delete to keep the order.
+// Pairs put in the map directly (e.g. &Hash{Pairs: m}) follow them in order of their keys.
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	keys  []HashKey // Keys in insertion order
+}
+
+// NewHash returns empty hash which has room for size pairs
+func NewHash(size int) *Hash {
+	return &Hash{
+		Pairs: make(map[HashKey]HashPair, size),
+		keys:  make([]HashKey, 0, size),
+	}
+}
+
+// Set adds pair to hash. Existing key keeps its position.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if h.Pairs == nil {
+		h.Pairs = map[HashKey]HashPair{}
+	}
+	if len(h.keys) != len(h.Pairs) {
+		h.syncKeys()
+	}
+	if _, ok := h.Pairs[key]; !ok {
+		h.keys = append(h.keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+// Delete removes pair of key from hash
+func (h *Hash) Delete(key HashKey) {
+	if _, ok := h.Pairs[key]; !ok {
+		return
+	}
+	if len(h.keys) != len(h.Pairs) {
+		h.syncKeys()
+	}
+	delete(h.Pairs, key)
+	for i, k := range h.keys {
+		if k == key {
+			h.keys = append(h.keys[:i], h.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// OrderedPairs returns pairs in insertion order
+func (h *Hash) OrderedPairs() []HashPair {
+	h.syncKeys()
+	pairs := make([]HashPair, 0, len(h.keys))
+	for _, k := range h.keys {
+		pairs = append(pairs, h.Pairs[k])
+	}
+	return pairs
+}
+
+// syncKeys makes keys consistent with Pairs changed without Set and Delete.
+// Keys removed from Pairs are dropped, and keys added to Pairs are appended in order of Inspect.
+func (h *Hash) syncKeys() {
+	if len(h.keys) == len(h.Pairs) {
+		consistent := true
+		for _, k := range h.keys {
+			if _, ok := h.Pairs[k]; !ok {
+				consistent = false
+				break
+			}
+		}
+		if consistent {
+			return
+		}
+	}
+
+	known := make(map[HashKey]bool, len(h.Pairs))
+	keys := make([]HashKey, 0, len(h.Pairs))
+	for _, k := range h.keys {
+		if _, ok := h.Pairs[k]; ok && !known[k] {
+			known[k] = true
+			keys = append(keys, k)
+		}
+	}
+	added := make([]HashKey, 0, len(h.Pairs)-len(keys))
+	for k := range h.Pairs {
+		if !known[k] {
+			added = append(added, k)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool {
+		return h.Pairs[added[i]].Key.Inspect() < h.Pairs[added[j]].Key.Inspect()
+	})
+	h.keys = append(keys, added...)
 }
 
 // Type returns 'HASH'
@@ -248,14 +611,24 @@ func (h *Hash) Type() ObjectType {
 	return HashObj
 }
 
-// Inspect returns associative array
+// Inspect returns associative array. Hash containing itself is shown as {...} inside.
 func (h *Hash) Inspect() string {
+	return h.inspect(map[Object]bool{})
+}
+
+func (h *Hash) inspect(seen map[Object]bool) string {
+	if seen[h] {
+		return "{...}"
+	}
+	seen[h] = true
+	defer delete(seen, h)
+
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.OrderedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+			pair.Key.Inspect(), inspectElement(pair.Value, seen)))
 	}
 
 	out.WriteString("{")
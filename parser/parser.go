@@ -1,10 +1,14 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
+	"sort"
 	"strconv"
 
 	"github.com/x-color/monkey/ast"
+	"github.com/x-color/monkey/diagnostic"
 	"github.com/x-color/monkey/lexer"
 	"github.com/x-color/monkey/token"
 )
@@ -13,26 +17,51 @@ import (
 const (
 	_ int = iota
 	Lowest
+	Assignment
+	LogicalOr
+	LogicalAnd
+	BitOr
+	BitXor
+	BitAnd
 	Equals
 	LessGreater
+	Shift
 	Sum
 	Product
 	Prefix
+	Power
 	Call
 	Index
 )
 
 var precedences = map[token.TokenType]int{
-	token.Eq:       Equals,
-	token.NotEq:    Equals,
-	token.Lt:       LessGreater,
-	token.Gt:       LessGreater,
-	token.Plus:     Sum,
-	token.Minus:    Sum,
-	token.Slash:    Product,
-	token.Asterisk: Product,
-	token.LParen:   Call,
-	token.LBracket: Index,
+	token.Assign:         Assignment,
+	token.PlusAssign:     Assignment,
+	token.MinusAssign:    Assignment,
+	token.AsteriskAssign: Assignment,
+	token.SlashAssign:    Assignment,
+	token.PercentAssign:  Assignment,
+	token.Or:             LogicalOr,
+	token.And:            LogicalAnd,
+	token.BitOr:          BitOr,
+	token.BitXor:         BitXor,
+	token.BitAnd:         BitAnd,
+	token.Eq:             Equals,
+	token.NotEq:          Equals,
+	token.Lt:             LessGreater,
+	token.Gt:             LessGreater,
+	token.LtEq:           LessGreater,
+	token.GtEq:           LessGreater,
+	token.LShift:         Shift,
+	token.RShift:         Shift,
+	token.Plus:           Sum,
+	token.Minus:          Sum,
+	token.Slash:          Product,
+	token.Asterisk:       Product,
+	token.Percent:        Product,
+	token.Power:          Power,
+	token.LParen:         Call,
+	token.LBracket:       Index,
 }
 
 type (
@@ -43,9 +72,15 @@ type (
 // Parser is program parser
 type Parser struct {
 	l              *lexer.Lexer
-	curToken       token.Token // Current parsing token
-	peekToken      token.Token // Next parsed token
-	errors         []string    // Parsing error list
+	curToken       token.Token              // Current parsing token
+	peekToken      token.Token              // Next parsed token
+	errors         []*diagnostic.Diagnostic // Parsing error list
+	recovering     bool                     // Whether error was found in current statement
+	stmtStart      int                      // Offset of current top-level statement
+	loopDepth      int                      // Number of loops enclosing current token in function
+	comments       []*ast.Comment           // All comments read
+	curComments    []*ast.Comment           // Comments just before current token
+	peekComments   []*ast.Comment           // Comments just before next token
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -54,15 +89,17 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*diagnostic.Diagnostic{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.Ident, p.parseIdentifier)
 	p.registerPrefix(token.Int, p.parseIntegerLiteral)
+	p.registerPrefix(token.Float, p.parseFloatLiteral)
 	p.registerPrefix(token.String, p.parseStringLiteral)
 	p.registerPrefix(token.Bang, p.parsePrefixExpression)
 	p.registerPrefix(token.Minus, p.parsePrefixExpression)
+	p.registerPrefix(token.BitNot, p.parsePrefixExpression)
 	p.registerPrefix(token.True, p.parseBoolean)
 	p.registerPrefix(token.False, p.parseBoolean)
 	p.registerPrefix(token.LParen, p.parseGroupedExpression)
@@ -71,16 +108,34 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBracket, p.parseArrayLiteral)
 	p.registerPrefix(token.LBrace, p.parseHashLiteral)
 	p.registerPrefix(token.While, p.parseWhileExpression)
+	p.registerPrefix(token.For, p.parseForExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.Plus, p.parseInfixExpression)
+	p.registerInfix(token.And, p.parseInfixExpression)
+	p.registerInfix(token.Or, p.parseInfixExpression)
 	p.registerInfix(token.Minus, p.parseInfixExpression)
 	p.registerInfix(token.Slash, p.parseInfixExpression)
 	p.registerInfix(token.Asterisk, p.parseInfixExpression)
+	p.registerInfix(token.Percent, p.parseInfixExpression)
+	p.registerInfix(token.Power, p.parseInfixExpression)
 	p.registerInfix(token.Eq, p.parseInfixExpression)
 	p.registerInfix(token.NotEq, p.parseInfixExpression)
 	p.registerInfix(token.Lt, p.parseInfixExpression)
 	p.registerInfix(token.Gt, p.parseInfixExpression)
+	p.registerInfix(token.LtEq, p.parseInfixExpression)
+	p.registerInfix(token.GtEq, p.parseInfixExpression)
+	p.registerInfix(token.BitAnd, p.parseInfixExpression)
+	p.registerInfix(token.BitOr, p.parseInfixExpression)
+	p.registerInfix(token.BitXor, p.parseInfixExpression)
+	p.registerInfix(token.LShift, p.parseInfixExpression)
+	p.registerInfix(token.RShift, p.parseInfixExpression)
+	p.registerInfix(token.Assign, p.parseAssignExpression)
+	p.registerInfix(token.PlusAssign, p.parseAssignExpression)
+	p.registerInfix(token.MinusAssign, p.parseAssignExpression)
+	p.registerInfix(token.AsteriskAssign, p.parseAssignExpression)
+	p.registerInfix(token.SlashAssign, p.parseAssignExpression)
+	p.registerInfix(token.PercentAssign, p.parseAssignExpression)
 	p.registerInfix(token.LParen, p.parseCallExpression)
 	p.registerInfix(token.LBracket, p.parseIndexExpression)
 
@@ -91,7 +146,16 @@ func New(l *lexer.Lexer) *Parser {
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	p.curComments = p.peekComments
+	p.peekComments = nil
+
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == token.Comment {
+		comment := &ast.Comment{Token: p.peekToken}
+		p.comments = append(p.comments, comment)
+		p.peekComments = append(p.peekComments, comment)
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 // ParseProgram makes AST from program code
@@ -100,23 +164,51 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != token.Eof {
+		p.stmtStart = p.curToken.Pos.Offset
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.recovering {
+			p.synchronize()
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	leading := p.curComments
+
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case token.Let:
-		return p.parseLetStatement()
+		stmt = p.parseLetStatement()
 	case token.Return:
-		return p.parseReturnStatement()
+		stmt = p.parseReturnStatement()
+	case token.Break:
+		stmt = p.parseBreakStatement()
+	case token.Continue:
+		stmt = p.parseContinueStatement()
 	default:
-		return p.parseExpressionStatement()
+		stmt = p.parseExpressionStatement()
+	}
+
+	if c, ok := stmt.(ast.Commented); ok && !p.recovering {
+		p.attachComments(c, leading)
+	}
+	return stmt
+}
+
+// attachComments attaches comments before statement and ones after it on the same line
+func (p *Parser) attachComments(stmt ast.Commented, leading []*ast.Comment) {
+	trivia := stmt.Comments()
+	trivia.Leading = leading
+
+	line := p.curToken.End.Line
+	for len(p.peekComments) > 0 && p.peekComments[0].Pos().Line == line {
+		trivia.Trailing = append(trivia.Trailing, p.peekComments[0])
+		p.peekComments = p.peekComments[1:]
 	}
 }
 
@@ -136,11 +228,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 	stmt.Value = p.parseExpression(Lowest)
 
-	if p.peekTokenIs(token.Semicolon) {
-		p.nextToken()
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
 	}
 
-	for !p.curTokenIs(token.Semicolon) {
+	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
 	}
 
@@ -153,13 +245,37 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	p.nextToken()
 	stmt.ReturnValue = p.parseExpression(Lowest)
 
-	for !p.curTokenIs(token.Semicolon) {
+	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
 	}
 
 	return stmt
 }
 
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	p.parseLoopControl()
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	p.parseLoopControl()
+	return stmt
+}
+
+// parseLoopControl checks 'break' or 'continue' is in loop and skips optional semicolon
+func (p *Parser) parseLoopControl() {
+	if p.loopDepth == 0 {
+		msg := fmt.Sprintf("%s is not in loop", p.curToken.Literal)
+		p.addError(diagnostic.OutsideLoop, msg, p.curToken, "")
+	}
+
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(Lowest)
@@ -198,12 +314,20 @@ func (p *Parser) parseBlockStatemnt() *ast.BlockStatement {
 	p.nextToken()
 	for !p.curTokenIs(token.RBrace) && !p.curTokenIs(token.Eof) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.recovering {
+			if p.synchronize() {
+				break
+			}
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	if p.curTokenIs(token.RBrace) {
+		block.RBrace = p.curToken
+	}
+
 	return block
 }
 
@@ -218,9 +342,30 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// Literal out of range of int64 is kept as big integer
+		if value, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = value
+			return lit
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(diagnostic.InvalidInteger, msg, p.curToken, "")
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(diagnostic.InvalidFloat, msg, p.curToken, "")
 		return nil
 	}
 
@@ -252,12 +397,38 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.curPrecedence()
+	if p.curTokenIs(token.Power) {
+		// '**' is right-associative (2 ** 3 ** 2 is 2 ** (3 ** 2))
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
 	return expression
 }
 
+// parseAssignExpression parses right-associative assignment (a = b = c is a = (b = c))
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		msg := fmt.Sprintf("cannot assign to %s", target.String())
+		p.addError(diagnostic.InvalidAssign, msg, p.curToken, "")
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(Assignment - 1)
+
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.True)}
 }
@@ -315,7 +486,11 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	// Loops outside function can not be exited from its body
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatemnt()
+	p.loopDepth = loopDepth
 
 	return lit
 }
@@ -350,12 +525,18 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RParen)
+	if p.curTokenIs(token.RParen) {
+		exp.RParen = p.curToken
+	}
 	return exp
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBracket)
+	if p.curTokenIs(token.RBracket) {
+		array.RBracket = p.curToken
+	}
 	return array
 }
 
@@ -392,13 +573,13 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RBracket) {
 		return nil
 	}
+	exp.RBracket = p.curToken
 
 	return exp
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
 	for !p.peekTokenIs(token.RBrace) {
 		p.nextToken()
@@ -411,7 +592,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(Lowest)
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 		if !p.peekTokenIs(token.RBrace) && !p.expectPeek(token.Comma) {
 			return nil
 		}
@@ -420,6 +601,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBrace) {
 		return nil
 	}
+	hash.RBrace = p.curToken
 
 	return hash
 }
@@ -441,11 +623,100 @@ func (p *Parser) parseWhileExpression() ast.Expression {
 		return nil
 	}
 
-	expression.Consequence = p.parseBlockStatemnt()
+	expression.Consequence = p.parseLoopBody()
+
+	return expression
+}
+
+// parseForExpression parses C-style 'for' loop or 'for ... in' loop
+func (p *Parser) parseForExpression() ast.Expression {
+	tok := p.curToken
+	if !p.expectPeek(token.LParen) {
+		return nil
+	}
+	p.nextToken()
+
+	if p.curTokenIs(token.Ident) && (p.peekTokenIs(token.In) || p.peekTokenIs(token.Comma)) {
+		return p.parseForInExpression(tok)
+	}
+
+	expression := &ast.ForExpression{Token: tok}
+	if !p.curTokenIs(token.Semicolon) {
+		if p.curTokenIs(token.Let) {
+			if stmt := p.parseLetStatement(); stmt != nil {
+				expression.Init = stmt
+			}
+		} else {
+			expression.Init = p.parseExpressionStatement()
+		}
+		if !p.curTokenIs(token.Semicolon) {
+			p.peekError(token.Semicolon)
+			return nil
+		}
+	}
+
+	if !p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+		expression.Condition = p.parseExpression(Lowest)
+	}
+	if !p.expectPeek(token.Semicolon) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.RParen) {
+		p.nextToken()
+		expression.Post = p.parseExpression(Lowest)
+	}
+	if !p.expectPeek(token.RParen) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBrace) {
+		return nil
+	}
+	expression.Body = p.parseLoopBody()
+
+	return expression
+}
+
+func (p *Parser) parseForInExpression(tok token.Token) ast.Expression {
+	expression := &ast.ForInExpression{Token: tok}
+	expression.Variables = []*ast.Identifier{{Token: p.curToken, Value: p.curToken.Literal}}
+
+	if p.peekTokenIs(token.Comma) {
+		p.nextToken()
+		if !p.expectPeek(token.Ident) {
+			return nil
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		expression.Variables = append(expression.Variables, ident)
+	}
+
+	if !p.expectPeek(token.In) {
+		return nil
+	}
+	p.nextToken()
+	expression.Iterable = p.parseExpression(Lowest)
+
+	if !p.expectPeek(token.RParen) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBrace) {
+		return nil
+	}
+	expression.Body = p.parseLoopBody()
 
 	return expression
 }
 
+// parseLoopBody parses block in which 'break' and 'continue' can be used
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatemnt()
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -465,18 +736,92 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(diagnostic.NoPrefixParseFn, msg, p.curToken, "")
 }
 
-// Errors returns parsing errors
+// Errors returns parsing error messages with position
 func (p *Parser) Errors() []string {
-	return p.errors
+	diagnostics := p.Diagnostics()
+	msgs := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		msgs[i] = d.Error()
+	}
+	return msgs
+}
+
+// Diagnostics returns lexing and parsing errors in source order
+func (p *Parser) Diagnostics() []*diagnostic.Diagnostic {
+	diagnostics := append(append([]*diagnostic.Diagnostic{}, p.l.Errors()...), p.errors...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Pos.Offset < diagnostics[j].Pos.Offset
+	})
+	return diagnostics
 }
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(diagnostic.UnexpectedToken, msg, p.peekToken, t)
+}
+
+// addError records error at tok unless error was already found in current statement.
+// Error at EOF is not recorded if lexer found error in current statement
+// (e.g. unterminated string), which is the cause of unexpected EOF.
+func (p *Parser) addError(code diagnostic.Code, msg string, tok token.Token, expected token.TokenType) {
+	if p.recovering {
+		return
+	}
+	p.recovering = true
+	if tok.Type == token.Eof && p.lexerErrorInStatement() {
+		return
+	}
+	p.errors = append(p.errors, &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Message:  msg,
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Expected: expected,
+		Actual:   tok,
+	})
+}
+
+// lexerErrorInStatement reports whether lexer found error in current top-level statement
+func (p *Parser) lexerErrorInStatement() bool {
+	for _, e := range p.l.Errors() {
+		if e.Pos.Offset >= p.stmtStart {
+			return true
+		}
+	}
+	return false
+}
+
+// synchronize skips tokens until end of broken statement.
+// It reports true if it stopped on '}' closing enclosing block.
+func (p *Parser) synchronize() bool {
+	p.recovering = false
+
+	depth := 0
+	for !p.curTokenIs(token.Eof) {
+		switch p.curToken.Type {
+		case token.LBrace:
+			depth++
+		case token.RBrace:
+			depth--
+			if depth < 0 {
+				return true
+			}
+		case token.Semicolon:
+			if depth == 0 {
+				return false
+			}
+		}
+		if depth == 0 && (p.peekTokenIs(token.RBrace) || p.peekTokenIs(token.Eof)) {
+			return false
+		}
+		p.nextToken()
+	}
+	return false
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
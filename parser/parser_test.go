@@ -2,10 +2,13 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/x-color/monkey/ast"
+	"github.com/x-color/monkey/diagnostic"
 	"github.com/x-color/monkey/lexer"
+	"github.com/x-color/monkey/token"
 )
 
 func TestLetStatement(t *testing.T) {
@@ -155,6 +158,54 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testLiteralExpression(t, stmt.Expression, 5)
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "123456789012345678901234567890;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.Big wrong. got=%v", literal.Big)
+	}
+	if literal.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.String() wrong. got=%s", literal.String())
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{"2E+3;", 2000},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestParsePrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -233,6 +284,207 @@ func TestParsingInfixExpressions(t *testing.T) {
 	}
 }
 
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    string
+	}{
+		{"x = 5;", "x", "=", "5"},
+		{"x += y * 2;", "x", "+=", "(y * 2)"},
+		{"x -= 1;", "x", "-=", "1"},
+		{"x *= 2;", "x", "*=", "2"},
+		{"x /= 2;", "x", "/=", "2"},
+		{"x %= 2;", "x", "%=", "2"},
+		{"arr[i + 1] = 0;", "(arr[(i + 1)])", "=", "0"},
+		{`h["key"] += 1;`, "(h[key])", "+=", "1"},
+		{"a = b = c;", "a", "=", "(b = c)"},
+		{"x = a || b;", "x", "=", "(a || b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+				1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+		if exp.Target.String() != tt.target {
+			t.Errorf("exp.Target wrong. want=%q, got=%q", tt.target, exp.Target.String())
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator wrong. want=%q, got=%q", tt.operator, exp.Operator)
+		}
+		if exp.Value.String() != tt.value {
+			t.Errorf("exp.Value wrong. want=%q, got=%q", tt.value, exp.Value.String())
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2;", "1:3: cannot assign to 1"},
+		{"f() = 2;", "1:5: cannot assign to f()"},
+		{"a + b = 2;", "1:7: cannot assign to (a + b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Fatalf("%q: wrong number of diagnostics. want=1, got=%d (%q)",
+				tt.input, len(diagnostics), p.Errors())
+		}
+		if diagnostics[0].Code != diagnostic.InvalidAssign {
+			t.Errorf("%q: wrong code. want=%s, got=%s",
+				tt.input, diagnostic.InvalidAssign, diagnostics[0].Code)
+		}
+		if p.Errors()[0] != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%q", tt.input, tt.expected, p.Errors()[0])
+		}
+	}
+}
+
+func TestLoopControlStatements(t *testing.T) {
+	input := `while (x) { if (y) { break; } continue }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	loop, ok := stmt.Expression.(*ast.WhileExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.WhileExpression. got=%T", stmt.Expression)
+	}
+	if len(loop.Consequence.Statements) != 2 {
+		t.Fatalf("loop body does not contain 2 statements. got=%d", len(loop.Consequence.Statements))
+	}
+
+	ifStmt := loop.Consequence.Statements[0].(*ast.ExpressionStatement)
+	ifExp := ifStmt.Expression.(*ast.IfExpression)
+	if _, ok := ifExp.Consequence.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("statement is not ast.BreakStatement. got=%T", ifExp.Consequence.Statements[0])
+	}
+	if _, ok := loop.Consequence.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("statement is not ast.ContinueStatement. got=%T", loop.Consequence.Statements[1])
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < 10; i += 1) { x }", "for (let i = 0; (i < 10); (i += 1)) x"},
+		{"for (i = 0; i < 10;) { x }", "for ((i = 0); (i < 10); ) x"},
+		{"for (;;) { break }", "for (; ; ) break;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.ForExpression); !ok {
+			t.Fatalf("stmt.Expression is not ast.ForExpression. got=%T", stmt.Expression)
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestForInExpression(t *testing.T) {
+	tests := []struct {
+		input     string
+		variables []string
+		expected  string
+	}{
+		{"for (x in arr) { x }", []string{"x"}, "for (x in arr) x"},
+		{"for (i, x in [1, 2]) { i }", []string{"i", "x"}, "for (i, x in [1,2]) i"},
+		{"for (k in range(3)) { k }", []string{"k"}, "for (k in range(3)) k"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		loop, ok := stmt.Expression.(*ast.ForInExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.ForInExpression. got=%T", stmt.Expression)
+		}
+		if len(loop.Variables) != len(tt.variables) {
+			t.Fatalf("wrong number of variables. want=%d, got=%d", len(tt.variables), len(loop.Variables))
+		}
+		for i, name := range tt.variables {
+			testIdentifier(t, loop.Variables[i], name)
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break is not in loop"},
+		{"if (x) { continue; }", "1:10: continue is not in loop"},
+		{"while (x) { fn() { break; } }", "1:20: break is not in loop"},
+		{"fn() { while (x) { } continue }", "1:22: continue is not in loop"},
+		{"for (x in y) { fn() { continue } }", "1:23: continue is not in loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Fatalf("%q: wrong number of diagnostics. want=1, got=%d (%q)",
+				tt.input, len(diagnostics), p.Errors())
+		}
+		if diagnostics[0].Code != diagnostic.OutsideLoop {
+			t.Errorf("%q: wrong code. want=%s, got=%s",
+				tt.input, diagnostic.OutsideLoop, diagnostics[0].Code)
+		}
+		if p.Errors()[0] != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%q", tt.input, tt.expected, p.Errors()[0])
+		}
+	}
+}
+
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -330,6 +582,70 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c < d",
+			"((a == b) && (c < d))",
+		},
+		{
+			"!a && -b || c",
+			"(((!a) && (-b)) || c)",
+		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a <= b == b >= c",
+			"((a <= b) == (b >= c))",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
+		{
+			"a ** -b",
+			"(a ** (-b))",
+		},
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b == c",
+			"(a & (b == c))",
+		},
+		{
+			"a << 1 + b >> c",
+			"((a << (1 + b)) >> c)",
+		},
+		{
+			"a < b << c",
+			"(a < (b << c))",
+		},
+		{
+			"a && b | c",
+			"(a && (b | c))",
+		},
+		{
+			"~a & ~-b",
+			"((~a) & (~(-b)))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -612,6 +928,39 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestParsingHashLiteral(t *testing.T) {
+	input := `{"two": 2, one: 1, 3: 1 + 2}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	// Pairs are kept in source order
+	if key, ok := hash.Pairs[0].Key.(*ast.StringLiteral); !ok || key.Value != "two" {
+		t.Errorf("first key is not \"two\". got=%s", hash.Pairs[0].Key)
+	}
+	testLiteralExpression(t, hash.Pairs[0].Value, 2)
+	testIdentifier(t, hash.Pairs[1].Key, "one")
+	testLiteralExpression(t, hash.Pairs[1].Value, 1)
+	testLiteralExpression(t, hash.Pairs[2].Key, 3)
+	testInfixExpression(t, hash.Pairs[2].Value, 1, "+", 2)
+
+	if actual := program.String(); actual != "{two:2, one:1, 3:(1 + 2)}" {
+		t.Errorf("wrong string. got=%q", actual)
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -749,3 +1098,285 @@ func testPrefixExpression(t *testing.T, exp ast.Expression,
 
 	return true
 }
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+		expectedEnd string
+	}{
+		{"foobar;", "1:1", "1:7"},
+		{"let x = 1 + 2;", "1:1", "1:14"},
+		{"return add(1,\n  2);", "1:1", "2:5"},
+		{"-a * b[0]", "1:1", "1:10"},
+		{"if (x) { y } else {\n z \n}", "1:1", "3:2"},
+		{"fn(x) { x }", "1:1", "1:12"},
+		{"[1, 2]", "1:1", "1:7"},
+		{`{"a": 1}`, "1:1", "1:9"},
+		{"while (x) {\n}", "1:1", "2:2"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt := program.Statements[0]
+		if stmt.Pos().String() != tt.expectedPos {
+			t.Errorf("%q: Pos() wrong. expected=%s, got=%s",
+				tt.input, tt.expectedPos, stmt.Pos())
+		}
+		if stmt.End().String() != tt.expectedEnd {
+			t.Errorf("%q: End() wrong. expected=%s, got=%s",
+				tt.input, tt.expectedEnd, stmt.End())
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{
+			"let x = (1;\nlet y = 2;",
+			[]string{"1:11: expected next token to be ), got ; instead"},
+		},
+		{
+			"let = 5; let y 10;",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"1:16: expected next token to be =, got INT instead",
+			},
+		},
+		{
+			"let f = fn(x) {\n  let = x;\n  x + 1;\n};\nf(1);",
+			[]string{"2:7: expected next token to be IDENT, got = instead"},
+		},
+		{
+			"if (x { y }; z",
+			[]string{"1:7: expected next token to be ), got { instead"},
+		},
+		{
+			"let f = fn() { let x = };\nlet y = 1;",
+			[]string{"1:24: no prefix parse function for } found"},
+		},
+		{
+			"}; let a = 1;",
+			[]string{"1:1: no prefix parse function for } found"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("%q: wrong number of errors. want=%d, got=%d (%q)",
+				tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("%q: errors[%d] wrong. want=%q, got=%q",
+					tt.input, i, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestParserRecoveryKeepsValidStatements(t *testing.T) {
+	input := `
+let a = 1;
+let b = ;
+let c = fn(x) { let = 1; x };
+let d = 4;
+`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 2 {
+		t.Fatalf("wrong number of errors. want=2, got=%d (%q)",
+			len(p.Errors()), p.Errors())
+	}
+
+	names := []string{}
+	for _, stmt := range program.Statements {
+		letStmt, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.LetStatement. got=%T", stmt)
+		}
+		names = append(names, letStmt.Name.Value)
+	}
+	if fmt.Sprint(names) != "[a c d]" {
+		t.Errorf("wrong statements parsed. got=%v", names)
+	}
+}
+
+func TestParserDiagnostic(t *testing.T) {
+	input := "let x = add(1, 2;"
+
+	l := lexer.NewWithFilename("test.mky", input)
+	p := New(l)
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. want=1, got=%d", len(diagnostics))
+	}
+
+	d := diagnostics[0]
+	if d.Code != diagnostic.UnexpectedToken {
+		t.Errorf("d.Code wrong. want=%s, got=%s", diagnostic.UnexpectedToken, d.Code)
+	}
+	if d.Severity != diagnostic.Error {
+		t.Errorf("d.Severity wrong. want=%s, got=%s", diagnostic.Error, d.Severity)
+	}
+	if d.Expected != token.RParen {
+		t.Errorf("d.Expected wrong. want=%s, got=%s", token.RParen, d.Expected)
+	}
+	if d.Actual.Type != token.Semicolon {
+		t.Errorf("d.Actual.Type wrong. want=%s, got=%s", token.Semicolon, d.Actual.Type)
+	}
+	if d.Pos.String() != "test.mky:1:17" {
+		t.Errorf("d.Pos wrong. want=%s, got=%s", "test.mky:1:17", d.Pos)
+	}
+
+	expected := "test.mky:1:17: error[P001]: expected next token to be ), got ; instead\n" +
+		" 1 | let x = add(1, 2;\n" +
+		"   |                 ^\n"
+	if d.Render(input) != expected {
+		t.Errorf("d.Render wrong.\nwant=%q\ngot=%q", expected, d.Render(input))
+	}
+}
+
+func TestParsingComments(t *testing.T) {
+	input := `// add two numbers
+// (documented)
+let add = fn(a, b) {
+	a + b // sum
+};
+add(1, 2); /* call */ // twice
+/* dangling */`
+
+	l := lexer.New(input)
+	l.KeepComments()
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Comments) != 6 {
+		t.Fatalf("program.Comments does not contain 6 comments. got=%d", len(program.Comments))
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	if texts := commentTexts(let.Leading); texts != " add two numbers| (documented)" {
+		t.Errorf("let leading comments wrong. got=%q", texts)
+	}
+	if len(let.Trailing) != 0 {
+		t.Errorf("let has trailing comments. got=%q", commentTexts(let.Trailing))
+	}
+
+	body := let.Value.(*ast.FunctionLiteral).Body
+	sum := body.Statements[0].(*ast.ExpressionStatement)
+	if texts := commentTexts(sum.Trailing); texts != " sum" {
+		t.Errorf("body trailing comments wrong. got=%q", texts)
+	}
+
+	call := program.Statements[1].(*ast.ExpressionStatement)
+	if len(call.Leading) != 0 {
+		t.Errorf("call has leading comments. got=%q", commentTexts(call.Leading))
+	}
+	if texts := commentTexts(call.Trailing); texts != " call | twice" {
+		t.Errorf("call trailing comments wrong. got=%q", texts)
+	}
+
+	l = lexer.New(input)
+	p = New(l)
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Comments) != 0 || len(program.Statements) != 2 {
+		t.Errorf("comments are not skipped. got=%d comments, %d statements",
+			len(program.Comments), len(program.Statements))
+	}
+}
+
+func commentTexts(comments []*ast.Comment) string {
+	texts := []string{}
+	for _, c := range comments {
+		texts = append(texts, c.Text())
+	}
+	return strings.Join(texts, "|")
+}
+
+func TestParserLexerErrors(t *testing.T) {
+	input := `let a = 1 +;
+let s = "a\qb";
+let t = "unterminated`
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	expected := []string{
+		"1:12: no prefix parse function for ; found",
+		"2:11: unknown escape sequence \\q",
+		"3:9: string literal not terminated",
+	}
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%q)", len(expected), len(errors), errors)
+	}
+	for i, e := range expected {
+		if errors[i] != e {
+			t.Errorf("errors[%d] wrong. want=%q, got=%q", i, e, errors[i])
+		}
+	}
+
+	d := p.Diagnostics()[1]
+	if d.Code != diagnostic.InvalidEscape {
+		t.Errorf("wrong code. want=%s, got=%s", diagnostic.InvalidEscape, d.Code)
+	}
+}
+
+func TestLexerErrorAtEOFHasNoCascade(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`puts("abc`, []string{"1:6: string literal not terminated"}},
+		{"let f = fn() { `abc", []string{"1:16: raw string literal not terminated"}},
+		{"foo(1, /* comment", []string{"1:8: comment not terminated"}},
+		{"let s = \"a\\qb\";\nputs(1", []string{
+			"1:11: unknown escape sequence \\q",
+			"2:7: expected next token to be ), got EOF instead",
+		}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%q: wrong number of errors. want=%d, got=%d (%q)",
+				tt.input, len(tt.expected), len(errors), errors)
+			continue
+		}
+		for i, e := range tt.expected {
+			if errors[i] != e {
+				t.Errorf("%q: errors[%d] wrong. want=%q, got=%q", tt.input, i, e, errors[i])
+			}
+		}
+	}
+}
@@ -1,34 +1,85 @@
 package token
 
+import "fmt"
+
 // TokenType is token type (literal, variable or operator ..)
 type TokenType string
 
+// Position is location in source code
+type Position struct {
+	Filename string // File name (empty if source is not a file)
+	Offset   int    // Byte offset, starting at 0
+	Line     int    // Line number, starting at 1
+	Column   int    // Column number in charactors, starting at 1
+}
+
+// IsValid checks if position is set
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns position (e.g. 'file:line:column' or 'line:column')
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
 // Token has token info
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // Position of first charactor of token
+	End     Position // Position just after last charactor of token
 }
 
 // Token types
 const (
 	Illegal = "ILLEGAL" // Illegal
 	Eof     = "EOF"     // End of file
+	Comment = "COMMENT" // Comment ('// ...' or '/* ... */')
 
 	Ident  = "IDENT"  // Variable
 	Int    = "INT"    // Integer literal
+	Float  = "FLOAT"  // Floating-point number literal
 	String = "STRING" // String literal
 
 	// operators
-	Assign   = "="
-	Plus     = "+"
-	Minus    = "-"
-	Bang     = "!"
-	Asterisk = "*"
-	Slash    = "/"
-	Lt       = "<"
-	Gt       = ">"
-	Eq       = "=="
-	NotEq    = "!="
+	Assign         = "="
+	PlusAssign     = "+="
+	MinusAssign    = "-="
+	AsteriskAssign = "*="
+	SlashAssign    = "/="
+	PercentAssign  = "%="
+	Plus           = "+"
+	Minus          = "-"
+	Bang           = "!"
+	Asterisk       = "*"
+	Slash          = "/"
+	Percent        = "%"
+	Power          = "**"
+	Lt             = "<"
+	Gt             = ">"
+	LtEq           = "<="
+	GtEq           = ">="
+	Eq             = "=="
+	NotEq          = "!="
+	And            = "&&"
+	Or             = "||"
+	BitAnd         = "&"
+	BitOr          = "|"
+	BitXor         = "^"
+	BitNot         = "~"
+	LShift         = "<<"
+	RShift         = ">>"
 
 	// delimeters
 	Comma     = ","
@@ -52,17 +103,25 @@ const (
 	While    = "WHILE"
 	Else     = "ELSE"
 	Return   = "RETURN"
+	Break    = "BREAK"
+	Continue = "CONTINUE"
+	For      = "FOR"
+	In       = "IN"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     Function,
-	"let":    Let,
-	"true":   True,
-	"false":  False,
-	"if":     If,
-	"while":  While,
-	"else":   Else,
-	"return": Return,
+	"fn":       Function,
+	"let":      Let,
+	"true":     True,
+	"false":    False,
+	"if":       If,
+	"while":    While,
+	"else":     Else,
+	"return":   Return,
+	"break":    Break,
+	"continue": Continue,
+	"for":      For,
+	"in":       In,
 }
 
 // LookupIdent checks if word is keyword
@@ -0,0 +1,33 @@
+package vm
+
+import (
+	"github.com/x-color/monkey/code"
+	"github.com/x-color/monkey/object"
+)
+
+// Frame is call frame of function being executed
+type Frame struct {
+	cl          *object.Closure
+	ip          int // Position of instruction being executed
+	basePointer int // Stack position of first local variable
+}
+
+// NewFrame makes new call frame
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+// Instructions returns instructions of function
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// span returns source code range of instruction being executed
+func (f *Frame) span() code.Span {
+	for ip := f.ip; ip >= 0; ip-- {
+		if span, ok := f.cl.Fn.SourceMap[ip]; ok {
+			return span
+		}
+	}
+	return code.Span{}
+}
@@ -0,0 +1,984 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/x-color/monkey/code"
+	"github.com/x-color/monkey/compiler"
+	"github.com/x-color/monkey/object"
+)
+
+// Sizes of vm stores
+const (
+	StackSize    = 2048 // Initial size of stack growing on demand
+	MaxStackSize = 1 << 20
+	GlobalsSize  = 65536
+	MaxFrames    = 10000 // Same as default maximum call depth of evaluator
+)
+
+// Constant boolean and null objects
+var (
+	Null  = object.NullValue
+	True  = object.TrueValue
+	False = object.FalseValue
+)
+
+// Operators shown in error messages
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+}
+
+// VM is virtual machine executing bytecode
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	openUpvalues []openUpvalue // Upvalues referring stack slots
+
+	io *object.IO // Standard input and outputs used by builtin functions
+}
+
+type openUpvalue struct {
+	slot    int
+	upvalue *object.Upvalue
+}
+
+// New makes new vm executing bytecode
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := []*Frame{mainFrame}
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+		stack:       make([]object.Object, StackSize),
+		sp:          0,
+		frames:      frames,
+		framesIndex: 1,
+		io:          object.DefaultIO(),
+	}
+}
+
+// SetIO sets standard input and outputs used by builtin functions (e.g. 'puts').
+// Process IO is used by default.
+func (vm *VM) SetIO(io *object.IO) {
+	vm.io = io
+}
+
+// IO returns standard input and outputs used by builtin functions
+func (vm *VM) IO() *object.IO {
+	return vm.io
+}
+
+// CheckAlloc always returns nil because vm has no limit of memory
+func (vm *VM) CheckAlloc(size int64) object.Object {
+	return nil
+}
+
+// NewWithGlobalsStore makes new vm keeping global variables of previous execution
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// LastPoppedStackElem returns value of last expression statement
+func (vm *VM) LastPoppedStackElem() object.Object {
+	if vm.sp >= len(vm.stack) {
+		return nil
+	}
+	return vm.stack[vm.sp]
+}
+
+// Run executes bytecode.
+// Runtime error is returned as *object.Error with its position and call stack.
+// Go panic in execution is returned as internal error.
+func (vm *VM) Run() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = vm.internalError(r)
+		}
+	}()
+	return vm.run(0)
+}
+
+// internalError returns error of Go panic r located at executing instruction
+func (vm *VM) internalError(r interface{}) error {
+	err := &object.Error{Message: fmt.Sprintf("internal error: %v", r)}
+	if vm.framesIndex == 0 {
+		return err
+	}
+	return vm.locateError(err)
+}
+
+// run executes instructions until frames above depth return
+func (vm *VM) run(depth int) error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.framesIndex > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			if err := vm.executeBangOperator(); err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			if err := vm.executeMinusOperator(); err != nil {
+				return err
+			}
+
+		case code.OpBitNot:
+			if err := vm.executeBitNotOperator(); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			val := vm.globals[globalIndex]
+			if val == nil {
+				return vm.identifierNotFound(vm.globalNames, int(globalIndex))
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			frame := vm.currentFrame()
+			val := vm.stack[frame.basePointer+int(localIndex)]
+			if val == nil {
+				return vm.identifierNotFound(frame.cl.Fn.LocalNames, int(localIndex))
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if vm.globals[globalIndex] == nil {
+				return vm.identifierNotFound(vm.globalNames, int(globalIndex))
+			}
+			vm.globals[globalIndex] = vm.stack[vm.sp-1]
+
+		case code.OpAssignLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			frame := vm.currentFrame()
+			slot := frame.basePointer + int(localIndex)
+			if vm.stack[slot] == nil {
+				return vm.identifierNotFound(frame.cl.Fn.LocalNames, int(localIndex))
+			}
+			vm.stack[slot] = vm.stack[vm.sp-1]
+
+		case code.OpAssignFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			cl := vm.currentFrame().cl
+			upvalue := cl.Free[freeIndex]
+			if *upvalue.Ref == nil {
+				return vm.identifierNotFound(cl.Fn.FreeNames, int(freeIndex))
+			}
+			*upvalue.Ref = vm.stack[vm.sp-1]
+
+		case code.OpAssignBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			return vm.newError("cannot assign to builtin function: %s", object.Builtins[builtinIndex].Name)
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			definition := object.Builtins[builtinIndex]
+			if err := vm.push(definition.Builtin); err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			cl := vm.currentFrame().cl
+			val := *cl.Free[freeIndex].Ref
+			if val == nil {
+				return vm.identifierNotFound(cl.Fn.FreeNames, int(freeIndex))
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(array); err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeIndexExpression(left, index); err != nil {
+				return err
+			}
+
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeSetIndex(left, index, val); err != nil {
+				return err
+			}
+
+		case code.OpDupPair:
+			left := vm.stack[vm.sp-2]
+			right := vm.stack[vm.sp-1]
+			if err := vm.push(left); err != nil {
+				return err
+			}
+			if err := vm.push(right); err != nil {
+				return err
+			}
+
+		case code.OpIter:
+			obj := vm.pop()
+			iter := object.Iterate(obj)
+			if iter == nil {
+				return vm.newError("not iterable: %s", obj.Type())
+			}
+			if err := vm.push(iter); err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			numVars := int(code.ReadUint8(ins[ip+1:]))
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			if err := vm.executeIterNext(numVars, pos); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				// 'return' in main program ends execution with popped value
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.pushClosure(int(constIndex)); err != nil {
+				return err
+			}
+
+		default:
+			return vm.newError("unknown opcode %d", op)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return vm.newError("stack overflow: too many nested function calls")
+	}
+	if vm.framesIndex < len(vm.frames) {
+		vm.frames[vm.framesIndex] = f
+	} else {
+		vm.frames = append(vm.frames, f)
+	}
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) error {
+	if err := vm.growStack(vm.sp + 1); err != nil {
+		return err
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// growStack grows stack so that it has size slots at least
+func (vm *VM) growStack(size int) error {
+	if size <= len(vm.stack) {
+		return nil
+	}
+	if size > MaxStackSize {
+		return vm.newError("stack overflow")
+	}
+
+	newSize := len(vm.stack) * 2
+	for newSize < size {
+		newSize *= 2
+	}
+	if newSize > MaxStackSize {
+		newSize = MaxStackSize
+	}
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+
+	// Open upvalues refer slots of old stack
+	for _, open := range vm.openUpvalues {
+		open.upvalue.Ref = &vm.stack[open.slot]
+	}
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftType := left.Type()
+	rightType := right.Type()
+
+	switch {
+	case leftType == object.IntegerObj && rightType == object.IntegerObj:
+		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
+	case leftType == object.StringObj && rightType == object.StringObj:
+		return vm.executeBinaryStringOperation(op, left, right)
+	case op == code.OpMul && leftType == object.StringObj && rightType == object.IntegerObj:
+		count, _ := object.ClampInt(right)
+		return vm.pushResult(object.RepeatString(vm, left.(*object.String).Value, count))
+	case op == code.OpMul && leftType == object.IntegerObj && rightType == object.StringObj:
+		count, _ := object.ClampInt(left)
+		return vm.pushResult(object.RepeatString(vm, right.(*object.String).Value, count))
+	case op == code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case op == code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	case leftType != rightType:
+		return vm.newError("type mismatch: %s %s %s",
+			leftType, infixOperators[op], rightType)
+	default:
+		return vm.newError("unknown operator: %s %s %s",
+			leftType, infixOperators[op], rightType)
+	}
+}
+
+// pushResult pushes result of operation implemented in object package or returns it if it is error
+func (vm *VM) pushResult(result object.Object) error {
+	if err, ok := result.(*object.Error); ok {
+		return vm.locateError(err)
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
+	// Result overflowing int64 is promoted to big integer
+	return vm.pushResult(object.IntegerInfix(infixOperators[op], left, right))
+}
+
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.Float{Value: leftValue + rightValue})
+	case code.OpSub:
+		return vm.push(&object.Float{Value: leftValue - rightValue})
+	case code.OpMul:
+		return vm.push(&object.Float{Value: leftValue * rightValue})
+	case code.OpDiv:
+		return vm.push(&object.Float{Value: leftValue / rightValue})
+	case code.OpMod:
+		return vm.push(&object.Float{Value: math.Mod(leftValue, rightValue)})
+	case code.OpPow:
+		return vm.push(&object.Float{Value: math.Pow(leftValue, rightValue)})
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	default:
+		return vm.newError("unknown operator: %s %s %s",
+			left.Type(), infixOperators[op], right.Type())
+	}
+}
+
+func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.String{Value: leftValue + rightValue})
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	default:
+		return vm.newError("unknown operator: %s %s %s",
+			left.Type(), infixOperators[op], right.Type())
+	}
+}
+
+func (vm *VM) executeBangOperator() error {
+	operand := vm.pop()
+
+	switch operand {
+	case True:
+		return vm.push(False)
+	case False:
+		return vm.push(True)
+	case Null:
+		return vm.push(True)
+	default:
+		return vm.push(False)
+	}
+}
+
+func (vm *VM) executeBitNotOperator() error {
+	operand := vm.pop()
+
+	if operand.Type() != object.IntegerObj {
+		return vm.newError("unknown operator: ~%s", operand.Type())
+	}
+	return vm.push(object.BitNotInteger(operand))
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	switch operand := operand.(type) {
+	case *object.Integer, *object.BigInt:
+		return vm.push(object.NegateInteger(operand))
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
+		return vm.newError("unknown operator: -%s", operand.Type())
+	}
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash((endIndex - startIndex) / 2)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, vm.newError("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ArrayObj:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HashObj:
+		return vm.executeHashIndex(left, index)
+	case left.Type() == object.StringObj:
+		i, ok := object.ClampInt(index)
+		if !ok {
+			return vm.newError("index of STRING must be INTEGER, got %s", index.Type())
+		}
+		return vm.push(object.CharAt(left.(*object.String).Value, i))
+	default:
+		return vm.newError("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	i, ok := object.ClampInt(index)
+	if !ok {
+		return vm.newError("index of ARRAY must be INTEGER, got %s", index.Type())
+	}
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if i < 0 || i > max {
+		return vm.push(Null)
+	}
+
+	return vm.push(arrayObject.Elements[i])
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return vm.newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(pair.Value)
+}
+
+// executeIterNext pushes next values of iterator for loop variables.
+// It jumps to pos if iterator is exhausted.
+func (vm *VM) executeIterNext(numVars, pos int) error {
+	iter, ok := vm.pop().(*object.Iterator)
+	if !ok {
+		return vm.newError("not iterator")
+	}
+
+	key, value, ok := iter.Next()
+	if !ok {
+		vm.currentFrame().ip = pos - 1
+		return nil
+	}
+
+	switch {
+	case numVars == 2:
+		if err := vm.push(key); err != nil {
+			return err
+		}
+		return vm.push(value)
+	case iter.Keyed:
+		return vm.push(key)
+	default:
+		return vm.push(value)
+	}
+}
+
+// executeSetIndex stores val as element of array or hash and pushes val
+func (vm *VM) executeSetIndex(left, index, val object.Object) error {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := object.ClampInt(index)
+		if !ok {
+			return vm.newError("index of ARRAY must be INTEGER, got %s", index.Type())
+		}
+		if i < 0 || i >= int64(len(left.Elements)) {
+			return vm.newError("index out of range: %s", index.Inspect())
+		}
+		left.Elements[i] = val
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return vm.newError("unusable as hash key: %s", index.Type())
+		}
+		left.Set(key.HashKey(), object.HashPair{Key: index, Value: val})
+
+	default:
+		return vm.newError("index assignment not supported: %s", left.Type())
+	}
+	return vm.push(val)
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return vm.newError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return vm.newError("wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
+	basePointer := vm.sp - numArgs
+	if err := vm.growStack(basePointer + cl.Fn.NumLocals); err != nil {
+		return err
+	}
+
+	frame := NewFrame(cl, basePointer)
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+
+	// Clear locals so that variables not bound yet are not found
+	for i := basePointer + numArgs; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Call(vm, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if err, ok := result.(*object.Error); ok {
+		if err.Pos.IsValid() || len(err.Stack) > 0 {
+			// Error raised in function called by builtin function is located already
+			return err
+		}
+		return vm.locateError(err)
+	}
+	if result == nil {
+		result = Null
+	}
+	return vm.push(result)
+}
+
+// Apply calls fn with args and returns its result.
+// Builtin functions call functions given as their arguments by it.
+// Go panic in execution is returned as internal error.
+func (vm *VM) Apply(fn object.Object, args ...object.Object) (result object.Object) {
+	depth := vm.framesIndex
+	defer func() {
+		if r := recover(); r != nil {
+			result = toError(vm.internalError(r))
+			vm.framesIndex = depth
+		}
+	}()
+
+	if err := vm.push(fn); err != nil {
+		return toError(err)
+	}
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			return toError(err)
+		}
+	}
+
+	if err := vm.executeCall(len(args)); err != nil {
+		return toError(err)
+	}
+	// Closure is executed until it returns
+	if err := vm.run(depth); err != nil {
+		return toError(err)
+	}
+
+	return vm.pop()
+}
+
+// toError converts error returned by vm to error object
+func toError(err error) *object.Error {
+	if e, ok := err.(*object.Error); ok {
+		return e
+	}
+	return &object.Error{Message: err.Error(), Cause: err}
+}
+
+func (vm *VM) pushClosure(constIndex int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return vm.newError("not a function: %+v", constant)
+	}
+
+	frame := vm.currentFrame()
+	free := make([]*object.Upvalue, len(function.Captures))
+	for i, c := range function.Captures {
+		if c.Local {
+			free[i] = vm.captureUpvalue(frame.basePointer + c.Index)
+		} else {
+			free[i] = frame.cl.Free[c.Index]
+		}
+	}
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
+// captureUpvalue returns upvalue referring stack slot shared by all closures capturing it
+func (vm *VM) captureUpvalue(slot int) *object.Upvalue {
+	for _, open := range vm.openUpvalues {
+		if open.slot == slot {
+			return open.upvalue
+		}
+	}
+
+	upvalue := &object.Upvalue{Ref: &vm.stack[slot]}
+	vm.openUpvalues = append(vm.openUpvalues, openUpvalue{slot: slot, upvalue: upvalue})
+	return upvalue
+}
+
+// closeUpvalues moves variables captured from returning frame into their upvalues
+func (vm *VM) closeUpvalues(basePointer int) {
+	open := vm.openUpvalues[:0]
+	for _, o := range vm.openUpvalues {
+		if o.slot >= basePointer {
+			o.upvalue.Close()
+		} else {
+			open = append(open, o)
+		}
+	}
+	vm.openUpvalues = open
+}
+
+func (vm *VM) identifierNotFound(names []string, index int) error {
+	name := ""
+	if index < len(names) {
+		name = names[index]
+	}
+	return vm.newError("identifier not found: %s", name)
+}
+
+func (vm *VM) newError(format string, a ...interface{}) error {
+	return vm.locateError(&object.Error{Message: fmt.Sprintf(format, a...)})
+}
+
+// locateError sets position of executing instruction and call stack to error
+func (vm *VM) locateError(err *object.Error) error {
+	if !err.Pos.IsValid() {
+		span := vm.currentFrame().span()
+		err.Pos = span.Pos
+		err.End = span.End
+	}
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		callee := vm.frames[i]
+		caller := vm.frames[i-1]
+		err.Stack = append(err.Stack, object.Frame{
+			Function: callee.cl.Fn.FunctionName(),
+			Pos:      caller.span().Pos,
+		})
+	}
+
+	return err
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case Null:
+		return false
+	case True:
+		return true
+	case False:
+		return false
+	default:
+		return true
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.IntegerObj || obj.Type() == object.FloatObj
+}
+
+// toFloat converts integer or float object to float64
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		return obj.Float()
+	default:
+		return obj.(*object.Float).Value
+	}
+}
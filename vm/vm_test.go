@@ -0,0 +1,111 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/x-color/monkey/compiler"
+	"github.com/x-color/monkey/lexer"
+	"github.com/x-color/monkey/object"
+	"github.com/x-color/monkey/parser"
+)
+
+func run(t *testing.T, input string) (object.Object, error) {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %q", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err := vm.Run()
+	return vm.LastPoppedStackElem(), err
+}
+
+func TestClosuresShareCapturedVariable(t *testing.T) {
+	input := `
+let make = fn() {
+	let x = 1;
+	let get = fn() { x };
+	let x = 10;
+	get
+};
+let get = make();
+get()`
+
+	result, err := run(t, input)
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	integer, ok := result.(*object.Integer)
+	if !ok || integer.Value != 10 {
+		t.Errorf("wrong result. want=10, got=%s", result.Inspect())
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	_, err := run(t, "let f = fn(n) { f(n + 1) }; f(0)")
+
+	e, ok := err.(*object.Error)
+	if !ok {
+		t.Fatalf("error is not *object.Error. got=%T (%+v)", err, err)
+	}
+	if !strings.HasPrefix(e.Message, "stack overflow") {
+		t.Errorf("wrong error message. got=%q", e.Message)
+	}
+	if len(e.Stack) == 0 {
+		t.Errorf("call stack is empty")
+	}
+}
+
+func TestWrongNumberOfArguments(t *testing.T) {
+	_, err := run(t, "fn(a, b) { a + b }(1)")
+	if err == nil || err.Error() != "wrong number of arguments: want=2, got=1" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	boom := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		panic("boom")
+	}}
+
+	symbolTable := compiler.NewSymbolTable()
+	symbolTable.Define("boom")
+	l := lexer.New("let f = fn() { boom() }; f()")
+	p := parser.New(l)
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(p.ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	globals := make([]object.Object, GlobalsSize)
+	globals[0] = boom
+	vm := NewWithGlobalsStore(comp.Bytecode(), globals)
+	err := vm.Run()
+	e, ok := err.(*object.Error)
+	if !ok {
+		t.Fatalf("error is not *object.Error. got=%T (%+v)", err, err)
+	}
+	if e.Message != "internal error: boom" {
+		t.Errorf("wrong error message. want=%q, got=%q", "internal error: boom", e.Message)
+	}
+	if e.Pos.Line != 1 || e.Pos.Column != 16 {
+		t.Errorf("wrong error position. want=1:16, got=%d:%d", e.Pos.Line, e.Pos.Column)
+	}
+	if len(e.Stack) != 1 {
+		t.Errorf("wrong call stack. got=%+v", e.Stack)
+	}
+
+	if result := vm.Apply(boom); result.Inspect() != "ERROR: internal error: boom" {
+		t.Errorf("wrong result of Apply. got=%s", result.Inspect())
+	}
+}